@@ -3,33 +3,33 @@ package api
 import (
 	"avito-backend-bootcamp/auth"
 	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/env"
 	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/moderation"
+	"context"
+	"io"
 	"log"
+	"math"
 	"net/http"
-	"strconv"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthOnlyAPI struct {
+	Houses        database.HouseRepository
+	Flats         database.FlatRepository
+	Subscriptions database.SubscriptionRepository
+	Tokens        database.TokenRepository
 }
 
 func (api *AuthOnlyAPI) FlatCreatePost(c *gin.Context) {
-	jwtTokenStr := c.GetHeader("Authorization")
-	if jwtTokenStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
-		return
-	}
-
-	_, err := auth.ValidateJwtToken(jwtTokenStr)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
-		return
-	}
+	claims := claimsFromContext(c)
 
 	var createFlatRequest models.FlatCreatePostRequest
-	if err := c.ShouldBindJSON(&createFlatRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &createFlatRequest) {
 		return
 	}
 
@@ -37,19 +37,48 @@ func (api *AuthOnlyAPI) FlatCreatePost(c *gin.Context) {
 		HouseId:    createFlatRequest.HouseId,
 		FlatNumber: createFlatRequest.FlatNumber,
 		Price:      createFlatRequest.Price,
+		Currency:   flatCurrency(createFlatRequest.Currency),
 		Rooms:      createFlatRequest.Rooms,
 		Status:     models.CREATED,
+		CreatedBy:  &claims.UserID,
 	}
 
-	if err := database.CreateFlat(&flat); err != nil {
-		log.Printf("Error creating flat: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create flat"})
+	house, err := api.Houses.GetHouseByID(c.Request.Context(), createFlatRequest.HouseId)
+	if err != nil {
+		log.Printf("Error fetching house: %v", err)
+		storageError(c, err, "Failed to fetch house")
 		return
 	}
 
-	if err := database.UpdateHouse(createFlatRequest.HouseId); err != nil {
-		log.Printf("Error updating house: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update house"})
+	if houseVisible(house, claims) {
+		decision := moderation.Evaluate(&flat, house, claims.Email)
+		flat.Status = decision.Status
+		err = api.Flats.CreateFlat(c.Request.Context(), &flat, decision.Reason)
+		if err == database.ErrFlatExists {
+			// Declined flats do not take a number, so the duplicate can be
+			// recorded as declined when a rule says so.
+			if decision, ok := moderation.EvaluateDuplicate(); ok {
+				flat.Status = decision.Status
+				err = api.Flats.CreateFlat(c.Request.Context(), &flat, decision.Reason)
+			}
+		}
+	} else {
+		err = database.ErrHouseNotFound
+	}
+	if err == database.ErrHouseNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "House not found", Code: models.ERROR_HOUSE_NOT_FOUND})
+		return
+	}
+	if err == database.ErrFlatExists {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Flat with this number already exists in the house",
+			Code:  models.ERROR_FLAT_EXISTS,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating flat: %v", err)
+		storageError(c, err, "Failed to create flat")
 		return
 	}
 
@@ -57,43 +86,546 @@ func (api *AuthOnlyAPI) FlatCreatePost(c *gin.Context) {
 }
 
 func (api *AuthOnlyAPI) HouseIdGet(c *gin.Context) {
-	jwtTokenStr := c.GetHeader("Authorization")
-	if jwtTokenStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+	claims := claimsFromContext(c)
+
+	houseID, ok := pathID(c)
+	if !ok {
 		return
 	}
 
-	claims, err := auth.ValidateJwtToken(jwtTokenStr)
+	house, err := api.Houses.GetHouseByID(c.Request.Context(), houseID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+		log.Printf("Error fetching house: %v", err)
+		storageError(c, err, "Failed to fetch house")
+		return
+	}
+
+	if !houseVisible(house, claims) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "House not found", Code: models.ERROR_HOUSE_NOT_FOUND})
 		return
 	}
 
-	houseID, err := strconv.Atoi(c.Param("id"))
+	var filter database.FlatFilter
+	if filter.Rooms, err = queryInt32(c, "rooms"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.PriceMin, err = queryInt64(c, "price_min"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.PriceMax, err = queryInt64(c, "price_max"); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	// Clients only ever see approved flats; moderators see everything unless
+	// they ask for one status.
+	if status := queryString(c, "status"); status != nil {
+		if claims.UserType != string(models.MODERATOR) {
+			respondError(c, http.StatusForbidden, models.ERROR_FORBIDDEN, "Only moderators can filter by status")
+			return
+		}
+		if !models.Status(*status).IsValid() {
+			badRequest(c, invalidField("status", models.FIELD_INVALID_VALUE, "is not a flat status"))
+			return
+		}
+		filter.Status = (*models.Status)(status)
+	} else if claims.UserType != string(models.MODERATOR) {
+		approved := models.APPROVED
+		filter.Status = &approved
+	}
+
+	order, err := queryFlatOrder(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid house ID"})
+		badRequest(c, err)
 		return
 	}
 
-	var flats []models.Flat
-	if claims.UserType == string(models.MODERATOR) {
-		flats, err = database.GetFlatsByHouseID(houseID, "all")
-	} else {
-		flats, err = database.GetFlatsByHouseID(houseID, string(models.APPROVED))
+	if filter.Currency, err = queryCurrency(c); err != nil {
+		badRequest(c, err)
+		return
+	}
+	byPrice := filter.PriceMin != nil || filter.PriceMax != nil || order.Sort == database.FlatSortPrice
+	if err := requireCurrency(filter.Currency, byPrice); err != nil {
+		badRequest(c, err)
+		return
 	}
+
+	limit, err := queryLimit(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	var cursor *database.FlatCursor
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodeFlatCursor(raw, order); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+
+	// One extra row tells whether there is a next page.
+	flats, err := api.Flats.GetFlatsByHouseID(c.Request.Context(), int(houseID), filter, order, cursor, limit+1)
 	if err != nil {
 		log.Printf("Error getting flats: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get flats"})
+		storageError(c, err, "Failed to get flats")
 		return
 	}
 
 	response := models.HouseIdGet200Response{
 		Flats: flats,
 	}
+	if len(flats) > limit {
+		response.Flats = flats[:limit]
+		response.NextCursor = encodeFlatCursor(flats[limit-1], order)
+	}
+
+	if err := api.markPriceDrops(c.Request.Context(), response.Flats); err != nil {
+		log.Printf("Error getting peak prices: %v", err)
+		storageError(c, err, "Failed to get flats")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// priceDropWindow is how far back HouseIdGet looks for a higher price when
+// flagging price drops. It can be overridden with PRICE_DROP_WINDOW, e.g.
+// "168h".
+var priceDropWindow = env.Duration("PRICE_DROP_WINDOW", 30*24*time.Hour)
+
+// markPriceDrops flags the flats whose price is below the highest one they
+// had within priceDropWindow, with the drop in percent of that price.
+func (api *AuthOnlyAPI) markPriceDrops(ctx context.Context, flats []models.Flat) error {
+	if len(flats) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, len(flats))
+	for i, flat := range flats {
+		ids[i] = flat.Id
+	}
+
+	peaks, err := api.Flats.GetPeakPrices(ctx, ids, time.Now().Add(-priceDropWindow))
+	if err != nil {
+		return err
+	}
+
+	for i := range flats {
+		peak, ok := peaks[flats[i].Id]
+		if !ok || flats[i].Price >= peak {
+			continue
+		}
+
+		percent := math.Round(float64(peak-flats[i].Price)/float64(peak)*1000) / 10
+		flats[i].PriceDropped = true
+		flats[i].PriceDropPercent = &percent
+	}
+	return nil
+}
+
+// HousesGet lists the house catalogue, most recently updated houses first
+// unless order=asc is given. Archived houses are listed for moderators only.
+func (api *AuthOnlyAPI) HousesGet(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	filter := database.HouseFilter{
+		IncludeArchived: claims.UserType == string(models.MODERATOR),
+	}
+	var err error
+
+	filter.Query = queryString(c, "q")
+	if filter.YearMin, err = queryInt32(c, "year_min"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.YearMax, err = queryInt32(c, "year_max"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.HasApprovedFlats, err = queryBool(c, "has_approved_flats"); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	descending, err := queryDescending(c, true)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	limit, err := queryLimit(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	var cursor *database.HouseCursor
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodeHouseCursor(raw, descending); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+
+	// One extra row tells whether there is a next page.
+	houses, err := api.Houses.SearchHouses(c.Request.Context(), filter, descending, cursor, limit+1)
+	if err != nil {
+		log.Printf("Error searching houses: %v", err)
+		storageError(c, err, "Failed to search houses")
+		return
+	}
+
+	response := models.HousesGet200Response{
+		Houses: houses,
+	}
+	if len(houses) > limit {
+		response.Houses = houses[:limit]
+		response.NextCursor = encodeHouseCursor(houses[limit-1], descending)
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
 func (api *AuthOnlyAPI) HouseIdSubscribePost(c *gin.Context) {
-	// Your handler implementation
-	c.JSON(200, gin.H{"status": "OK"})
+	claims := claimsFromContext(c)
+
+	houseID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	// Users subscribe the address they logged in with; subscribing anyone
+	// else's would let them send mail to strangers. The body may still name
+	// the address, as the specification describes, but it has to be theirs.
+	var subscribeRequest models.HouseIdSubscribePostRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &subscribeRequest) {
+		return
+	}
+
+	email := strings.ToLower(claims.Email)
+	if subscribeRequest.Email != "" {
+		address, err := mail.ParseAddress(strings.TrimSpace(subscribeRequest.Email))
+		if err != nil {
+			badRequest(c, invalidField("email", models.FIELD_INVALID_EMAIL, "must be a valid email address"))
+			return
+		}
+		if strings.ToLower(address.Address) != email {
+			respondError(c, http.StatusForbidden, models.ERROR_FORBIDDEN, "Only your own email can be subscribed")
+			return
+		}
+	}
+
+	house, err := api.Houses.GetHouseByID(c.Request.Context(), houseID)
+	if err != nil {
+		log.Printf("Error fetching house: %v", err)
+		storageError(c, err, "Failed to fetch house")
+		return
+	}
+
+	if !houseVisible(house, claims) {
+		respondError(c, http.StatusNotFound, models.ERROR_HOUSE_NOT_FOUND, "House not found")
+		return
+	}
+
+	subscription := models.Subscription{
+		HouseId: house.Id,
+		Email:   email,
+	}
+
+	if err := api.Subscriptions.CreateSubscription(c.Request.Context(), &subscription); err != nil {
+		log.Printf("Error creating subscription: %v", err)
+		storageError(c, err, "Failed to create subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (api *AuthOnlyAPI) SubscriptionsGet(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	subscriptions, err := api.Subscriptions.GetSubscriptionsByEmail(c.Request.Context(), strings.ToLower(claims.Email))
+	if err != nil {
+		log.Printf("Error getting subscriptions: %v", err)
+		storageError(c, err, "Failed to get subscriptions")
+		return
+	}
+
+	response := models.SubscriptionsGet200Response{
+		Subscriptions: subscriptions,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (api *AuthOnlyAPI) SubscriptionsIdDelete(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	subscriptionID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := api.Subscriptions.GetSubscriptionByID(c.Request.Context(), subscriptionID)
+	if err != nil {
+		log.Printf("Error fetching subscription: %v", err)
+		storageError(c, err, "Failed to fetch subscription")
+		return
+	}
+
+	if subscription == nil || subscription.Email != strings.ToLower(claims.Email) {
+		respondError(c, http.StatusNotFound, models.ERROR_SUBSCRIPTION_NOT_FOUND, "Subscription not found")
+		return
+	}
+
+	if err := api.Subscriptions.DeleteSubscription(c.Request.Context(), subscription.Id); err != nil {
+		log.Printf("Error deleting subscription: %v", err)
+		storageError(c, err, "Failed to delete subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (api *AuthOnlyAPI) LogoutPost(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	var logoutRequest models.LogoutPostRequest
+	if err := c.ShouldBindJSON(&logoutRequest); err != nil && err != io.EOF {
+		badRequest(c, bindingError(err))
+		return
+	}
+
+	var refreshHash string
+	if logoutRequest.RefreshToken != "" {
+		refreshHash = auth.HashRefreshToken(logoutRequest.RefreshToken)
+	}
+
+	err := api.Tokens.RevokeSession(c.Request.Context(), claims.Id, time.Unix(claims.ExpiresAt, 0), refreshHash)
+	if err != nil {
+		log.Printf("Error logging out: %v", err)
+		storageError(c, err, "Failed to log out")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (api *AuthOnlyAPI) FlatStatusesGet(c *gin.Context) {
+	response := models.FlatStatusesGet200Response{
+		Transitions: models.StatusTransitions,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// FlatIdPatch edits a flat. Its author's edits send it back to moderation;
+// moderators may edit any flat without changing its status.
+func (api *AuthOnlyAPI) FlatIdPatch(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	flatID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var patchRequest models.FlatIdPatchRequest
+	if !bindJSON(c, &patchRequest) {
+		return
+	}
+
+	var currency *string
+	if patchRequest.Currency != nil {
+		code := flatCurrency(*patchRequest.Currency)
+		currency = &code
+	}
+
+	flat, ok := api.flatForChange(c, flatID)
+	if !ok {
+		return
+	}
+
+	edit := database.FlatEdit{
+		FlatNumber: patchRequest.FlatNumber,
+		Price:      patchRequest.Price,
+		Currency:   currency,
+		Rooms:      patchRequest.Rooms,
+		Remoderate: claims.UserType != string(models.MODERATOR),
+	}
+
+	edited, err := api.Flats.EditFlat(c.Request.Context(), flat.Id, edit, claims.UserID)
+	switch err {
+	case nil:
+	case database.ErrFlatNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Flat not found",
+			Code:  models.ERROR_FLAT_NOT_FOUND,
+		})
+		return
+	case database.ErrFlatExists:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Flat with this number already exists in the house",
+			Code:  models.ERROR_FLAT_EXISTS,
+		})
+		return
+	case database.ErrInvalidStatusTransition:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Flat cannot be sent back to moderation",
+			Code:  models.ERROR_INVALID_STATUS_TRANSITION,
+		})
+		return
+	default:
+		log.Printf("Error editing flat: %v", err)
+		storageError(c, err, "Failed to edit flat")
+		return
+	}
+
+	c.JSON(http.StatusOK, edited)
+}
+
+// FlatIdDelete withdraws a flat. Authors may withdraw their own flats and
+// moderators any flat.
+func (api *AuthOnlyAPI) FlatIdDelete(c *gin.Context) {
+	flatID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	flat, ok := api.flatForChange(c, flatID)
+	if !ok {
+		return
+	}
+
+	err := api.Flats.DeleteFlat(c.Request.Context(), flat.Id)
+	if err == database.ErrFlatNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Flat not found",
+			Code:  models.ERROR_FLAT_NOT_FOUND,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting flat: %v", err)
+		storageError(c, err, "Failed to delete flat")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// flatForChange fetches a flat the caller wants to change and checks that
+// they are a moderator or the flat's author. It responds and reports false
+// otherwise.
+func (api *AuthOnlyAPI) flatForChange(c *gin.Context, id int32) (*models.Flat, bool) {
+	claims := claimsFromContext(c)
+
+	flat, err := api.Flats.GetFlatByID(c.Request.Context(), id)
+	if err == database.ErrFlatNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Flat not found",
+			Code:  models.ERROR_FLAT_NOT_FOUND,
+		})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching flat: %v", err)
+		storageError(c, err, "Failed to fetch flat")
+		return nil, false
+	}
+
+	isAuthor := flat.CreatedBy != nil && *flat.CreatedBy == claims.UserID
+	if claims.UserType != string(models.MODERATOR) && !isAuthor {
+		respondError(c, http.StatusForbidden, models.ERROR_FORBIDDEN, "Only moderators and the flat's author can change it")
+		return nil, false
+	}
+
+	return flat, true
+}
+
+// FlatIdPricesGet returns a flat's price history. Clients may see it for
+// the flats they can see in HouseIdGet and for their own flats.
+func (api *AuthOnlyAPI) FlatIdPricesGet(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	flatID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	flat, err := api.Flats.GetFlatByID(c.Request.Context(), flatID)
+	if err == database.ErrFlatNotFound {
+		respondError(c, http.StatusNotFound, models.ERROR_FLAT_NOT_FOUND, "Flat not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching flat: %v", err)
+		storageError(c, err, "Failed to fetch flat")
+		return
+	}
+
+	isAuthor := flat.CreatedBy != nil && *flat.CreatedBy == claims.UserID
+	if claims.UserType != string(models.MODERATOR) && !isAuthor {
+		house, err := api.Houses.GetHouseByID(c.Request.Context(), flat.HouseId)
+		if err != nil {
+			log.Printf("Error fetching house: %v", err)
+			storageError(c, err, "Failed to fetch house")
+			return
+		}
+
+		if flat.Status != models.APPROVED || !houseVisible(house, claims) {
+			respondError(c, http.StatusNotFound, models.ERROR_FLAT_NOT_FOUND, "Flat not found")
+			return
+		}
+	}
+
+	prices, err := api.Flats.GetFlatPrices(c.Request.Context(), flat.Id)
+	if err != nil {
+		log.Printf("Error getting flat prices: %v", err)
+		storageError(c, err, "Failed to get flat prices")
+		return
+	}
+
+	response := models.FlatIdPricesGet200Response{
+		Prices: prices,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (api *AuthOnlyAPI) FlatIdHistoryGet(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	flatID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	flat, err := api.Flats.GetFlatByID(c.Request.Context(), flatID)
+	if err == database.ErrFlatNotFound {
+		respondError(c, http.StatusNotFound, models.ERROR_FLAT_NOT_FOUND, "Flat not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching flat: %v", err)
+		storageError(c, err, "Failed to fetch flat")
+		return
+	}
+
+	isAuthor := flat.CreatedBy != nil && *flat.CreatedBy == claims.UserID
+	if claims.UserType != string(models.MODERATOR) && !isAuthor {
+		respondError(c, http.StatusForbidden, models.ERROR_FORBIDDEN, "Only moderators and the flat's author can view its history")
+		return
+	}
+
+	history, err := api.Flats.GetFlatStatusHistory(c.Request.Context(), flat.Id)
+	if err != nil {
+		log.Printf("Error getting flat status history: %v", err)
+		storageError(c, err, "Failed to get flat status history")
+		return
+	}
+
+	response := models.FlatIdHistoryGet200Response{
+		History: history,
+	}
+	c.JSON(http.StatusOK, response)
 }
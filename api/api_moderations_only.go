@@ -1,106 +1,349 @@
 package api
 
 import (
-	"avito-backend-bootcamp/auth"
 	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/env"
 	"avito-backend-bootcamp/models"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ModerationsOnlyAPI struct {
+	Houses database.HouseRepository
+	Flats  database.FlatRepository
 }
 
+// moderationLease is how long a moderator keeps a flat after putting it "on
+// moderation". It can be overridden with MODERATION_LEASE, e.g. "45m".
+var moderationLease = env.Duration("MODERATION_LEASE", 30*time.Minute)
+
 func (api *ModerationsOnlyAPI) FlatUpdatePost(c *gin.Context) {
-	jwtTokenStr := c.GetHeader("Authorization")
+	claims := claimsFromContext(c)
 
-	if jwtTokenStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+	var updateFlatRequest models.FlatUpdatePostRequest
+	if !bindJSON(c, &updateFlatRequest) {
 		return
 	}
 
-	claims, err := auth.ValidateJwtToken(jwtTokenStr)
+	flat, err := api.Flats.UpdateFlatStatus(c.Request.Context(), updateFlatRequest.Id, string(updateFlatRequest.Status), claims.UserID,
+		updateFlatRequest.Reason, moderationLease)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+		if code, response, ok := flatStatusError(err, updateFlatRequest.Status); ok {
+			c.JSON(code, response)
+			return
+		}
+		log.Printf("Error updating flat status: %v", err)
+		storageError(c, err, "Failed to update flat status")
 		return
 	}
 
-	if claims.UserType != string(models.MODERATOR) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderator can update flat status"})
+	c.JSON(http.StatusOK, flat)
+}
+
+// flatStatusError translates the flat errors returned by the database when
+// changing a status. It reports false for any other error.
+func flatStatusError(err error, status models.Status) (int, models.ErrorResponse, bool) {
+	switch err {
+	case database.ErrFlatNotFound:
+		return http.StatusNotFound, models.ErrorResponse{
+			Error: "Flat not found",
+			Code:  models.ERROR_FLAT_NOT_FOUND,
+		}, true
+	case database.ErrInvalidStatusTransition:
+		return http.StatusBadRequest, models.ErrorResponse{
+			Error: "Flat status cannot be changed to " + string(status),
+			Code:  models.ERROR_INVALID_STATUS_TRANSITION,
+		}, true
+	case database.ErrFlatClaimed:
+		return http.StatusConflict, models.ErrorResponse{
+			Error: "Flat is already under moderation",
+			Code:  models.ERROR_FLAT_CLAIMED,
+		}, true
+	case database.ErrFlatNotClaimed:
+		return http.StatusConflict, models.ErrorResponse{
+			Error: "Flat must be taken into moderation by you first",
+			Code:  models.ERROR_FLAT_NOT_CLAIMED,
+		}, true
+	}
+
+	return 0, models.ErrorResponse{}, false
+}
+
+// bulkItemError describes why one update of a batch failed. Besides the flat
+// errors, a best-effort batch reports the storage error it stopped at and
+// the updates it did not attempt after it.
+func bulkItemError(err error, status models.Status) models.ErrorResponse {
+	if _, errorResponse, ok := flatStatusError(err, status); ok {
+		return errorResponse
+	}
+
+	switch {
+	case err == database.ErrNotAttempted:
+		return models.ErrorResponse{Error: "Not attempted because an earlier update failed", Code: models.ERROR_NOT_ATTEMPTED}
+	case err == database.ErrUserNotFound:
+		return models.ErrorResponse{Error: "User not found", Code: models.ERROR_UNAUTHORIZED}
+	case database.IsCanceled(err):
+		return models.ErrorResponse{Error: "Request timed out", Code: models.ERROR_TIMEOUT}
+	default:
+		log.Printf("Error updating flat status: %v", err)
+		return models.ErrorResponse{Error: "Failed to update flat status", Code: models.ERROR_INTERNAL}
+	}
+}
+
+func (api *ModerationsOnlyAPI) HouseCreatePost(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	var createHouseRequest models.HouseCreatePostRequest
+
+	if !bindJSON(c, &createHouseRequest) {
 		return
 	}
 
-	var updateFlatRequest models.FlatUpdatePostRequest
-	if err := c.ShouldBindJSON(&updateFlatRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	house := models.House{
+		Address:   createHouseRequest.Address,
+		Year:      createHouseRequest.Year,
+		Developer: createHouseRequest.Developer,
+		CreatedAt: time.Now(),
+		UpdateAt:  time.Now(),
+		CreatedBy: &claims.UserID,
+	}
+
+	if err := api.Houses.CreateHouse(c.Request.Context(), &house); err != nil {
+		log.Printf("Error creating house: %v", err)
+		storageError(c, err, "Failed to create house")
+		return
+	}
+
+	c.JSON(http.StatusOK, house)
+}
+
+func (api *ModerationsOnlyAPI) HouseIdPatch(c *gin.Context) {
+	houseID, ok := pathID(c)
+	if !ok {
 		return
 	}
 
-	currentFlat, err := database.GetFlatByID(updateFlatRequest.Id)
+	var patchRequest models.HouseIdPatchRequest
+	if !bindJSON(c, &patchRequest) {
+		return
+	}
+
+	if patchRequest.Address != nil {
+		address := strings.TrimSpace(*patchRequest.Address)
+		patchRequest.Address = &address
+	}
+
+	edit := database.HouseEdit{
+		Address:   patchRequest.Address,
+		Year:      patchRequest.Year,
+		Developer: patchRequest.Developer,
+	}
+
+	house, err := api.Houses.EditHouse(c.Request.Context(), houseID, edit)
+	if err == database.ErrHouseNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "House not found", Code: models.ERROR_HOUSE_NOT_FOUND})
+		return
+	}
 	if err != nil {
-		log.Printf("Error fetching flat: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flat"})
+		log.Printf("Error editing house: %v", err)
+		storageError(c, err, "Failed to edit house")
 		return
 	}
 
-	if currentFlat.Status == models.ON_MODERATION && updateFlatRequest.Status == models.ON_MODERATION {
-		c.JSON(http.StatusConflict, gin.H{"error": "Flat is already under moderation"})
+	c.JSON(http.StatusOK, house)
+}
+
+// HouseIdArchivePost hides a house and its flats from clients. Moderators
+// keep seeing both.
+func (api *ModerationsOnlyAPI) HouseIdArchivePost(c *gin.Context) {
+	houseID, ok := pathID(c)
+	if !ok {
 		return
 	}
 
-	flat, err := database.UpdateFlatStatus(updateFlatRequest.Id, string(updateFlatRequest.Status))
+	house, err := api.Houses.ArchiveHouse(c.Request.Context(), houseID)
+	if err == database.ErrHouseNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "House not found", Code: models.ERROR_HOUSE_NOT_FOUND})
+		return
+	}
 	if err != nil {
-		log.Printf("Error updating flat status: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flat status"})
+		log.Printf("Error archiving house: %v", err)
+		storageError(c, err, "Failed to archive house")
 		return
 	}
 
-	c.JSON(http.StatusOK, flat)
+	c.JSON(http.StatusOK, house)
 }
 
-func (api *ModerationsOnlyAPI) HouseCreatePost(c *gin.Context) {
-	jwtTokenStr := c.GetHeader("Authorization")
+const maxQueueAssignment = 50
 
-	if jwtTokenStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+func (api *ModerationsOnlyAPI) ModerationQueueGet(c *gin.Context) {
+	var filter database.QueueFilter
+	var err error
+
+	if filter.HouseId, err = queryInt32(c, "house_id"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.PriceMin, err = queryInt64(c, "price_min"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.PriceMax, err = queryInt64(c, "price_max"); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if filter.Currency, err = queryCurrency(c); err != nil {
+		badRequest(c, err)
 		return
 	}
+	if err := requireCurrency(filter.Currency, filter.PriceMin != nil || filter.PriceMax != nil); err != nil {
+		badRequest(c, err)
+		return
+	}
+	filter.Developer = queryString(c, "developer")
 
-	claims, err := auth.ValidateJwtToken(jwtTokenStr)
+	limit, err := queryLimit(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	var cursor *database.QueueCursor
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodeQueueCursor(raw); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
 
+	// One extra row tells whether there is a next page.
+	flats, err := api.Flats.GetModerationQueue(c.Request.Context(), filter, cursor, limit+1)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+		log.Printf("Error getting moderation queue: %v", err)
+		storageError(c, err, "Failed to get moderation queue")
 		return
 	}
 
-	if claims.UserType != string(models.MODERATOR) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderator can create house"})
+	response := models.ModerationQueueGet200Response{
+		Flats: flats,
+	}
+	if len(flats) > limit {
+		response.Flats = flats[:limit]
+		response.NextCursor = encodeQueueCursor(flats[limit-1])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (api *ModerationsOnlyAPI) ModerationQueueAssignPost(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	var assignRequest models.ModerationQueueAssignPostRequest
+	if !bindJSON(c, &assignRequest) {
 		return
 	}
 
-	var createHouseRequest models.HouseCreatePostRequest
+	count := int(assignRequest.Count)
+	if count == 0 {
+		count = 1
+	}
+	if count < 1 || count > maxQueueAssignment {
+		badRequest(c, invalidField("count", models.FIELD_INVALID_VALUE, fmt.Sprintf("must be between 1 and %d", maxQueueAssignment)))
+		return
+	}
 
-	if err := c.ShouldBindJSON(&createHouseRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	filter := database.QueueFilter{
+		HouseId:   assignRequest.HouseId,
+		Developer: assignRequest.Developer,
+		PriceMin:  assignRequest.PriceMin,
+		PriceMax:  assignRequest.PriceMax,
+	}
+	if assignRequest.Currency != nil {
+		currency := flatCurrency(*assignRequest.Currency)
+		filter.Currency = &currency
+	}
+	if err := requireCurrency(filter.Currency, filter.PriceMin != nil || filter.PriceMax != nil); err != nil {
+		badRequest(c, err)
 		return
 	}
 
-	house := models.House{
-		Address:   createHouseRequest.Address,
-		Year:      createHouseRequest.Year,
-		Developer: createHouseRequest.Developer,
-		CreatedAt: time.Now(),
-		UpdateAt:  time.Now(),
+	flats, err := api.Flats.AssignFromQueue(c.Request.Context(), filter, count, claims.UserID, moderationLease)
+	if err != nil {
+		log.Printf("Error assigning flats: %v", err)
+		storageError(c, err, "Failed to assign flats")
+		return
 	}
 
-	if err := database.CreateHouse(&house); err != nil {
-		log.Printf("Error creating house: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create house"})
+	response := models.ModerationQueueAssignPost200Response{
+		Flats: flats,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (api *ModerationsOnlyAPI) FlatUpdateBulkPost(c *gin.Context) {
+	claims := claimsFromContext(c)
+
+	var bulkRequest models.FlatUpdateBulkPostRequest
+	if !bindJSON(c, &bulkRequest) {
 		return
 	}
 
-	c.JSON(http.StatusOK, house)
+	if bulkRequest.Mode == "" {
+		bulkRequest.Mode = models.BULK_BEST_EFFORT
+	}
+
+	updates := make([]database.FlatStatusUpdate, len(bulkRequest.Items))
+	for i, item := range bulkRequest.Items {
+		updates[i] = database.FlatStatusUpdate{Id: item.Id, Status: item.Status}
+	}
+
+	atomic := bulkRequest.Mode == models.BULK_ATOMIC
+	results, err := api.Flats.UpdateFlatStatuses(c.Request.Context(), updates, claims.UserID, bulkRequest.Reason, moderationLease, atomic)
+	if err != nil {
+		log.Printf("Error updating flat statuses: %v", err)
+		storageError(c, err, "Failed to update flat statuses")
+		return
+	}
+
+	failed := false
+	response := models.FlatUpdateBulkPost200Response{
+		Mode:    bulkRequest.Mode,
+		Results: make([]models.FlatUpdateBulkResult, len(results)),
+	}
+	for i, result := range results {
+		item := models.FlatUpdateBulkResult{Id: updates[i].Id}
+		if result.Err != nil {
+			errorResponse := bulkItemError(result.Err, updates[i].Status)
+			item.Error = &errorResponse
+			failed = true
+		} else {
+			item.Success = true
+			item.Flat = result.Flat
+		}
+		response.Results[i] = item
+	}
+
+	// An atomic batch with a single failure was rolled back as a whole.
+	if atomic && failed {
+		for i := range response.Results {
+			if response.Results[i].Success {
+				response.Results[i].Success = false
+				response.Results[i].Flat = nil
+				response.Results[i].Error = &models.ErrorResponse{
+					Error: "Rolled back because another flat in the batch failed",
+					Code:  models.ERROR_ROLLED_BACK,
+				}
+			}
+		}
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
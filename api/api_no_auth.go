@@ -4,6 +4,7 @@ import (
 	"avito-backend-bootcamp/auth"
 	"avito-backend-bootcamp/database"
 	"avito-backend-bootcamp/models"
+	"context"
 	"log"
 	"net/http"
 
@@ -13,6 +14,44 @@ import (
 )
 
 type NoAuthAPI struct {
+	Users         database.UserRepository
+	Subscriptions database.SubscriptionRepository
+	Tokens        database.TokenRepository
+}
+
+// unusablePassword is stored for accounts that cannot log in with a password.
+// It is not a valid bcrypt hash, so every comparison against it fails.
+const unusablePassword = "!"
+
+// issueTokens starts a new refresh token family for a fresh login and returns
+// it together with a short-lived access token.
+func (api *NoAuthAPI) issueTokens(ctx context.Context, user *models.User) (*models.DummyLoginGet200Response, error) {
+	jwtToken, err := auth.GenerateJwtToken(user.ID, user.Email, user.UserType)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.Tokens.CreateRefreshToken(ctx, &models.RefreshToken{
+		TokenHash: refreshToken.Hash,
+		FamilyId:  uuid.New().String(),
+		UserId:    user.ID,
+		Email:     user.Email,
+		UserType:  user.UserType,
+		ExpiresAt: refreshToken.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DummyLoginGet200Response{
+		Token:        jwtToken,
+		RefreshToken: refreshToken.Token,
+	}, nil
 }
 
 func (api *NoAuthAPI) DummyLoginGet(c *gin.Context) {
@@ -38,8 +77,16 @@ func (api *NoAuthAPI) DummyLoginGet(c *gin.Context) {
 		return
 	}
 
-	jwtToken, err := auth.GenerateJwtToken("dummylogin@example.com", string(dummyLoginRequest.UserType))
-	if err != nil {
+	// Every user type gets its own persistent account, so tokens from
+	// dummyLogin carry a stable user ID like regular ones.
+	user := models.User{
+		Email:    "dummylogin+" + string(dummyLoginRequest.UserType) + "@example.com",
+		Password: unusablePassword,
+		UserType: string(dummyLoginRequest.UserType),
+	}
+
+	if err := api.Users.EnsureUser(c.Request.Context(), &user); err != nil {
+		log.Printf("Error creating dummy user: %v", err)
 		response := models.DummyLoginGet500Response{
 			Message:   "Failed to generate token",
 			RequestId: uuid.New().String(),
@@ -49,8 +96,16 @@ func (api *NoAuthAPI) DummyLoginGet(c *gin.Context) {
 		return
 	}
 
-	response := models.DummyLoginGet200Response{
-		Token: jwtToken,
+	response, err := api.issueTokens(c.Request.Context(), &user)
+	if err != nil {
+		log.Printf("Error issuing tokens: %v", err)
+		response := models.DummyLoginGet500Response{
+			Message:   "Failed to generate token",
+			RequestId: uuid.New().String(),
+			Code:      http.StatusInternalServerError,
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		return
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -59,59 +114,59 @@ func (api *NoAuthAPI) DummyLoginGet(c *gin.Context) {
 func (api *NoAuthAPI) LoginPost(c *gin.Context) {
 	var loginRequest models.LoginPostRequest
 
-	if err := c.ShouldBindJSON(&loginRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &loginRequest) {
 		return
 	}
 
-	user, err := database.GetUserByEmail(loginRequest.Email)
-	if err != nil || user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	user, err := api.Users.GetUserByEmail(c.Request.Context(), loginRequest.Email)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		storageError(c, err, "Failed to fetch user")
+		return
+	}
+	if user == nil {
+		respondError(c, http.StatusUnauthorized, models.ERROR_INVALID_CREDENTIALS, "Invalid email or password")
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		respondError(c, http.StatusUnauthorized, models.ERROR_INVALID_CREDENTIALS, "Invalid email or password")
 		return
 	}
 
-	jwtToken, err := auth.GenerateJwtToken(user.Email, user.UserType)
+	response, err := api.issueTokens(c.Request.Context(), user)
 	if err != nil {
-		response := models.DummyLoginGet500Response{
-			Message:   "Failed to generate token",
-			RequestId: uuid.New().String(),
-			Code:      http.StatusInternalServerError,
-		}
-		c.JSON(http.StatusInternalServerError, response)
+		log.Printf("Error issuing tokens: %v", err)
+		storageError(c, err, "Failed to generate token")
 		return
 	}
 
-	response := models.DummyLoginGet200Response{
-		Token: jwtToken,
-	}
-
 	c.JSON(http.StatusOK, response)
 }
 
 func (api *NoAuthAPI) RegisterPost(c *gin.Context) {
 	var registerRequest models.RegisterPostRequest
 
-	if err := c.ShouldBindJSON(&registerRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &registerRequest) {
 		return
 	}
 
-	if registerRequest.UserType != models.CLIENT && registerRequest.UserType != models.MODERATOR {
-		log.Printf("Invalid user type: %v", registerRequest.UserType)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user type"})
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerRequest.Password), bcrypt.DefaultCost)
+	if err == bcrypt.ErrPasswordTooLong {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid request",
+			Code:  models.ERROR_VALIDATION_FAILED,
+			Fields: []models.FieldError{{
+				Field:   "password",
+				Code:    models.FIELD_TOO_LARGE,
+				Message: "must be at most 72 bytes",
+			}},
+		})
 		return
 	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerRequest.Password), bcrypt.DefaultCost)
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondError(c, http.StatusInternalServerError, models.ERROR_INTERNAL, "Failed to hash password")
 		return
 	}
 
@@ -121,11 +176,116 @@ func (api *NoAuthAPI) RegisterPost(c *gin.Context) {
 		UserType: string(registerRequest.UserType),
 	}
 
-	if err := database.CreateUser(&user); err != nil {
+	err = api.Users.CreateUser(c.Request.Context(), &user)
+	if err == database.ErrUserExists {
+		respondError(c, http.StatusConflict, models.ERROR_USER_EXISTS, "User already exists")
+		return
+	}
+	if err != nil {
 		log.Printf("Error creating user: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		storageError(c, err, "Failed to create user")
 		return
 	}
 
 	c.JSON(200, gin.H{"status": "OK"})
 }
+
+// UnsubscribeGet asks the holder of an unsubscribe link to confirm. It does
+// not change anything, see UnsubscribePost.
+func (api *NoAuthAPI) UnsubscribeGet(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := auth.ValidateUnsubscribeToken(token); err != nil {
+		respondError(c, http.StatusBadRequest, models.ERROR_INVALID_TOKEN, "Invalid unsubscribe token")
+		return
+	}
+
+	renderPage(c, confirmUnsubscribePage, token)
+}
+
+func (api *NoAuthAPI) UnsubscribePost(c *gin.Context) {
+	var unsubscribeRequest models.UnsubscribePostRequest
+
+	if err := c.ShouldBind(&unsubscribeRequest); err != nil {
+		badRequest(c, bindingError(err))
+		return
+	}
+
+	claims, err := auth.ValidateUnsubscribeToken(unsubscribeRequest.Token)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ERROR_INVALID_TOKEN, "Invalid unsubscribe token")
+		return
+	}
+
+	subscription, err := api.Subscriptions.GetSubscriptionByID(c.Request.Context(), claims.SubscriptionId)
+	if err != nil {
+		log.Printf("Error fetching subscription: %v", err)
+		storageError(c, err, "Failed to fetch subscription")
+		return
+	}
+
+	// Confirming twice is not an error.
+	if subscription == nil || subscription.Email != claims.Email {
+		renderPage(c, unsubscribedPage, nil)
+		return
+	}
+
+	if err := api.Subscriptions.DeleteSubscription(c.Request.Context(), subscription.Id); err != nil {
+		log.Printf("Error deleting subscription: %v", err)
+		storageError(c, err, "Failed to delete subscription")
+		return
+	}
+
+	renderPage(c, unsubscribedPage, nil)
+}
+
+func (api *NoAuthAPI) AuthRefreshPost(c *gin.Context) {
+	var refreshRequest models.AuthRefreshPostRequest
+
+	if !bindJSON(c, &refreshRequest) {
+		return
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, models.ERROR_INTERNAL, "Failed to generate token")
+		return
+	}
+
+	next := models.RefreshToken{
+		TokenHash: refreshToken.Hash,
+		ExpiresAt: refreshToken.ExpiresAt,
+	}
+
+	err = api.Tokens.RotateRefreshToken(c.Request.Context(), auth.HashRefreshToken(refreshRequest.RefreshToken), &next)
+	if err == database.ErrRefreshTokenReused {
+		log.Printf("Refresh token reuse detected, token family revoked")
+		respondError(c, http.StatusUnauthorized, models.ERROR_INVALID_TOKEN, "Invalid refresh token")
+		return
+	}
+	if err == database.ErrRefreshTokenInvalid {
+		respondError(c, http.StatusUnauthorized, models.ERROR_INVALID_TOKEN, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		storageError(c, err, "Failed to refresh token")
+		return
+	}
+
+	jwtToken, err := auth.GenerateJwtToken(next.UserId, next.Email, next.UserType)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, models.ERROR_INTERNAL, "Failed to generate token")
+		return
+	}
+
+	response := models.DummyLoginGet200Response{
+		Token:        jwtToken,
+		RefreshToken: refreshToken.Token,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (api *NoAuthAPI) JwksGet(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.JWKS())
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"avito-backend-bootcamp/auth"
+	"avito-backend-bootcamp/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsContextKey is where the authentication middleware stores the caller's
+// validated *auth.Claims.
+const ClaimsContextKey = "claims"
+
+// claimsFromContext returns the claims stored by the authentication
+// middleware. Handlers are only registered behind that middleware when they
+// require a caller, so the claims are always present for them.
+func claimsFromContext(c *gin.Context) *auth.Claims {
+	return c.MustGet(ClaimsContextKey).(*auth.Claims)
+}
+
+// houseVisible reports whether the caller may see house. Archived houses are
+// hidden from everyone but moderators.
+func houseVisible(house *models.House, claims *auth.Claims) bool {
+	return house != nil && (house.ArchivedAt == nil || claims.UserType == string(models.MODERATOR))
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursors are opaque to clients: the key of the last returned row, base64
+// encoded so that nobody is tempted to build them by hand.
+
+var errInvalidCursor = invalidField("cursor", models.FIELD_INVALID_VALUE, "is invalid")
+
+func encodeQueueCursor(flat models.Flat) string {
+	raw := flat.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(int(flat.Id))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeQueueCursor(cursor string) (*database.QueueCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, errInvalidCursor
+	}
+
+	parsedCreatedAt, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parsedId, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &database.QueueCursor{CreatedAt: parsedCreatedAt, Id: int32(parsedId)}, nil
+}
+
+// Flat cursors also carry the order they were issued for, so that a cursor
+// cannot be replayed against a different sort.
+
+func encodeFlatCursor(flat models.Flat, order database.FlatOrder) string {
+	raw := fmt.Sprintf("%s|%s|%d|%d", order.Sort, orderDirection(order.Descending), order.Sort.Value(flat), flat.Id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFlatCursor(cursor string, order database.FlatOrder) (*database.FlatCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 {
+		return nil, errInvalidCursor
+	}
+
+	if parts[0] != string(order.Sort) || parts[1] != orderDirection(order.Descending) {
+		return nil, invalidField("cursor", models.FIELD_INVALID_VALUE, "was issued for a different sort")
+	}
+
+	value, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(parts[3], 10, 32)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &database.FlatCursor{Value: value, Id: int32(id)}, nil
+}
+
+func orderDirection(descending bool) string {
+	if descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func encodeHouseCursor(house models.House, descending bool) string {
+	raw := orderDirection(descending) + "|" + house.UpdateAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(int(house.Id))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHouseCursor(cursor string, descending bool) (*database.HouseCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, errInvalidCursor
+	}
+
+	if parts[0] != orderDirection(descending) {
+		return nil, invalidField("cursor", models.FIELD_INVALID_VALUE, "was issued for a different sort")
+	}
+
+	updateAt, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 32)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &database.HouseCursor{UpdateAt: updateAt, Id: int32(id)}, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosedRequest is the nginx convention for a request the client
+// abandoned before the response was ready.
+const statusClientClosedRequest = 499
+
+// validationError reports invalid fields of a request: its body, query or
+// path parameters.
+type validationError struct {
+	fields []models.FieldError
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, 0, len(e.fields))
+	for _, field := range e.fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// invalidField returns a validationError for a single field.
+func invalidField(field, code, message string) error {
+	return &validationError{fields: []models.FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// respondError responds with an ErrorResponse.
+func respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, models.ErrorResponse{Error: message, Code: code})
+}
+
+// badRequest responds 400 to invalid input. Validation errors list the
+// offending fields; anything else means the request could not be parsed.
+func badRequest(c *gin.Context, err error) {
+	var invalid *validationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:  "Invalid request",
+			Code:   models.ERROR_VALIDATION_FAILED,
+			Fields: invalid.fields,
+		})
+		return
+	}
+	respondError(c, http.StatusBadRequest, models.ERROR_MALFORMED_REQUEST, fmt.Sprintf("Malformed request body: %v", err))
+}
+
+// pathID reads the "id" path parameter. It responds 400 and reports false
+// when the parameter is not an integer.
+func pathID(c *gin.Context) (int32, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		badRequest(c, invalidField("id", models.FIELD_INVALID_TYPE, "must be an integer"))
+		return 0, false
+	}
+	return int32(id), true
+}
+
+// AbortWithStorageError is storageError for middleware, which also has to
+// stop the handlers that follow it.
+func AbortWithStorageError(c *gin.Context, err error, message string) {
+	storageError(c, err, message)
+	c.Abort()
+}
+
+// storageError responds to a failed repository call. Calls cut short by the
+// query timeout become 504 so that they are not mistaken for bugs, and calls
+// abandoned by the client are aborted without a body nobody would read. A
+// caller whose user no longer exists gets 401, as a revoked token would.
+func storageError(c *gin.Context, err error, message string) {
+	if err == database.ErrUserNotFound {
+		respondError(c, http.StatusUnauthorized, models.ERROR_UNAUTHORIZED, "User not found")
+		return
+	}
+	if database.IsCanceled(err) {
+		if c.Request.Context().Err() != nil {
+			c.AbortWithStatus(statusClientClosedRequest)
+			return
+		}
+		respondError(c, http.StatusGatewayTimeout, models.ERROR_TIMEOUT, "Request timed out")
+		return
+	}
+	respondError(c, http.StatusInternalServerError, models.ERROR_INTERNAL, message)
+}
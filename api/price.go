@@ -0,0 +1,15 @@
+package api
+
+import "avito-backend-bootcamp/models"
+
+// flatCurrency returns the canonical code of a flat's currency, which the
+// request binding has already validated. An empty code means the default
+// currency.
+func flatCurrency(code string) string {
+	if code == "" {
+		return models.DefaultCurrency
+	}
+
+	currency, _ := models.ParseCurrency(code)
+	return currency
+}
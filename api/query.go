@@ -0,0 +1,153 @@
+package api
+
+import (
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// queryInt32 reads an optional integer query parameter. A missing parameter
+// yields nil.
+func queryInt32(c *gin.Context, name string) (*int32, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		return nil, invalidField(name, models.FIELD_INVALID_TYPE, "must be an integer")
+	}
+
+	result := int32(value)
+	return &result, nil
+}
+
+// queryInt64 is queryInt32 for 64-bit values such as prices.
+func queryInt64(c *gin.Context, name string) (*int64, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, invalidField(name, models.FIELD_INVALID_TYPE, "must be an integer")
+	}
+
+	return &value, nil
+}
+
+func queryString(c *gin.Context, name string) *string {
+	raw, ok := c.GetQuery(name)
+	if !ok || raw == "" {
+		return nil
+	}
+
+	return &raw
+}
+
+// queryCurrency reads the optional "currency" query parameter as a
+// canonical ISO 4217 code.
+func queryCurrency(c *gin.Context) (*string, error) {
+	raw := queryString(c, "currency")
+	if raw == nil {
+		return nil, nil
+	}
+
+	currency, ok := models.ParseCurrency(*raw)
+	if !ok {
+		return nil, invalidField("currency", models.FIELD_INVALID_CURRENCY, "must be an ISO 4217 currency code")
+	}
+	return &currency, nil
+}
+
+// requireCurrency rejects price bounds or a price sort without a currency.
+func requireCurrency(currency *string, byPrice bool) error {
+	if currency == nil && byPrice {
+		return invalidField("currency", models.FIELD_REQUIRED, "is required to filter or sort by price")
+	}
+	return nil
+}
+
+// queryLimit reads the page size from the "limit" query parameter.
+func queryLimit(c *gin.Context) (int, error) {
+	limit, err := queryInt32(c, "limit")
+	if err != nil {
+		return 0, err
+	}
+
+	if limit == nil {
+		return defaultPageSize, nil
+	}
+
+	if *limit < 1 {
+		return 0, invalidField("limit", models.FIELD_TOO_SMALL, "must be at least 1")
+	}
+	if *limit > maxPageSize {
+		return 0, invalidField("limit", models.FIELD_TOO_LARGE, fmt.Sprintf("must be at most %d", maxPageSize))
+	}
+
+	return int(*limit), nil
+}
+
+// queryFlatOrder reads the "sort" and "order" query parameters. Flats are
+// ordered by flat number, ascending, by default.
+func queryFlatOrder(c *gin.Context) (database.FlatOrder, error) {
+	order := database.FlatOrder{Sort: database.FlatSortFlatNumber}
+
+	if sort := queryString(c, "sort"); sort != nil {
+		switch database.FlatSort(*sort) {
+		case database.FlatSortFlatNumber, database.FlatSortPrice, database.FlatSortRooms:
+			order.Sort = database.FlatSort(*sort)
+		default:
+			return order, invalidField("sort", models.FIELD_INVALID_VALUE, "must be one of flat_number, price, rooms")
+		}
+	}
+
+	descending, err := queryDescending(c, false)
+	if err != nil {
+		return order, err
+	}
+	order.Descending = descending
+
+	return order, nil
+}
+
+// queryDescending reads the "order" query parameter, "asc" or "desc".
+func queryDescending(c *gin.Context, fallback bool) (bool, error) {
+	switch c.Query("order") {
+	case "":
+		return fallback, nil
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, invalidField("order", models.FIELD_INVALID_VALUE, "must be one of asc, desc")
+	}
+}
+
+// queryBool reads an optional boolean query parameter. A missing parameter
+// yields nil.
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, invalidField(name, models.FIELD_INVALID_TYPE, "must be a boolean")
+	}
+
+	return &value, nil
+}
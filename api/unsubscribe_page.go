@@ -0,0 +1,38 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// The unsubscribe link in emails only leads to confirmUnsubscribePage: mail
+// scanners and link previews follow links, and they must not cancel
+// subscriptions. The subscription is deleted when the form is submitted.
+var confirmUnsubscribePage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<p>Stop receiving emails about new flats in this house?</p>
+<form method="post" action="/unsubscribe">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+var unsubscribedPage = template.Must(template.New("unsubscribed").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribed</title></head>
+<body>
+<p>You will no longer receive emails about new flats in this house.</p>
+</body>
+</html>
+`))
+
+func renderPage(c *gin.Context, page *template.Template, data interface{}) {
+	c.Render(http.StatusOK, render.HTML{Template: page, Data: data})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"avito-backend-bootcamp/env"
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const minPasswordLength = 8
+
+// houseYearsAhead is how many years past the current one a house may be
+// completed in, for houses still under construction.
+var houseYearsAhead = env.Int("HOUSE_YEARS_AHEAD", 5)
+
+// Request models declare their constraints in binding tags. Besides the
+// validator's built-in ones, these are available:
+//
+//   - password: at least minPasswordLength characters, with a letter and a digit
+//   - house_year: no later than houseYearsAhead years from now
+//   - currency: an ISO 4217 code, in any case
+//   - notblank: a string with something besides white space
+//   - flat_status: one of the flat statuses
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	engine.RegisterTagNameFunc(jsonFieldName)
+	for tag, validate := range map[string]validator.Func{
+		"password":    validPassword,
+		"house_year":  validHouseYear,
+		"currency":    validCurrency,
+		"notblank":    notBlank,
+		"flat_status": validFlatStatus,
+	} {
+		if err := engine.RegisterValidation(tag, validate); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// jsonFieldName names fields in validation errors the way clients send them.
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func validPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return len([]rune(password)) >= minPasswordLength && letter && digit
+}
+
+func maxHouseYear() int {
+	return time.Now().Year() + houseYearsAhead
+}
+
+func validHouseYear(fl validator.FieldLevel) bool {
+	return fl.Field().Int() <= int64(maxHouseYear())
+}
+
+func validCurrency(fl validator.FieldLevel) bool {
+	_, ok := models.ParseCurrency(fl.Field().String())
+	return ok
+}
+
+func notBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+func flatStatuses() []string {
+	statuses := make([]string, 0, len(models.StatusTransitions))
+	for _, transition := range models.StatusTransitions {
+		statuses = append(statuses, string(transition.From))
+	}
+	return statuses
+}
+
+func validFlatStatus(fl validator.FieldLevel) bool {
+	return models.Status(fl.Field().String()).IsValid()
+}
+
+// bindJSON decodes and validates the request body. An invalid body gets a
+// 400 with an ErrorResponse, and bindJSON reports false.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		badRequest(c, bindingError(err))
+		return false
+	}
+	return true
+}
+
+// bindingError turns the binding's validation and type errors into a
+// validationError. Other errors mean the body is not JSON at all.
+func bindingError(err error) error {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		invalid := &validationError{}
+		for _, fieldErr := range validationErrors {
+			invalid.fields = append(invalid.fields, fieldError(fieldErr))
+		}
+		return invalid
+	case errors.As(err, &typeError):
+		return invalidField(typeError.Field, models.FIELD_INVALID_TYPE, fmt.Sprintf("must be %s", typeError.Type))
+	default:
+		return err
+	}
+}
+
+func fieldError(err validator.FieldError) models.FieldError {
+	// The namespace starts with the request type, which means nothing to
+	// clients.
+	_, field, _ := strings.Cut(err.Namespace(), ".")
+
+	unit := ""
+	switch err.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice:
+		unit = " items"
+	}
+
+	result := models.FieldError{Field: field, Code: models.FIELD_INVALID_VALUE, Message: "is invalid"}
+	switch err.Tag() {
+	case "required", "notblank":
+		result.Code, result.Message = models.FIELD_REQUIRED, "is required"
+	case "gt":
+		result.Code, result.Message = models.FIELD_TOO_SMALL, fmt.Sprintf("must be greater than %s", err.Param())
+	case "gte", "min":
+		result.Code, result.Message = models.FIELD_TOO_SMALL, fmt.Sprintf("must be at least %s%s", err.Param(), unit)
+	case "lte", "max":
+		result.Code, result.Message = models.FIELD_TOO_LARGE, fmt.Sprintf("must be at most %s%s", err.Param(), unit)
+	case "oneof":
+		result.Message = fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(err.Param()), ", "))
+	case "email":
+		result.Code, result.Message = models.FIELD_INVALID_EMAIL, "must be a valid email address"
+	case "password":
+		result.Code = models.FIELD_WEAK_PASSWORD
+		result.Message = fmt.Sprintf("must be at least %d characters long and contain a letter and a digit", minPasswordLength)
+	case "house_year":
+		result.Code, result.Message = models.FIELD_TOO_LARGE, fmt.Sprintf("must be at most %d", maxHouseYear())
+	case "currency":
+		result.Code, result.Message = models.FIELD_INVALID_CURRENCY, "must be an ISO 4217 currency code"
+	case "flat_status":
+		result.Message = "must be one of " + strings.Join(flatStatuses(), ", ")
+	}
+	return result
+}
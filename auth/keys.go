@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"avito-backend-bootcamp/models"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// secretKid identifies the optional HS256 key read from JWT_SECRET_KEY. Tokens
+// without a kid header are checked against it as well.
+const secretKid = "secret"
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var keys *keySet
+
+// LoadKeys reads the signing keys and must be called before any token is
+// issued or validated. Keys come from two places:
+//
+//   - JWT_KEYS_DIR: a directory of PEM files. "<kid>.pem" holds an RSA or EC
+//     private key that can sign; "<kid>.pub.pem" holds a public key that is
+//     only used for verification, which is how a retired key is kept around
+//     until the tokens it signed have expired.
+//   - JWT_SECRET_KEY: an HS256 secret. It is never published in the JWKS.
+//
+// JWT_ACTIVE_KID selects the signing key. It may be omitted when there is a
+// single private key. An error is returned when no key material is found.
+func LoadKeys() error {
+	set := &keySet{keys: map[string]*signingKey{}}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		if err := set.loadDir(dir); err != nil {
+			return err
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET_KEY"); secret != "" {
+		set.keys[secretKid] = &signingKey{
+			kid:     secretKid,
+			method:  jwt.SigningMethodHS256,
+			private: []byte(secret),
+			public:  []byte(secret),
+		}
+	}
+
+	if len(set.keys) == 0 {
+		return fmt.Errorf("no JWT key material: set JWT_KEYS_DIR or JWT_SECRET_KEY")
+	}
+
+	activeKid := os.Getenv("JWT_ACTIVE_KID")
+	if activeKid == "" {
+		var candidates []string
+		for kid, key := range set.keys {
+			if key.private != nil && kid != secretKid {
+				candidates = append(candidates, kid)
+			}
+		}
+		switch {
+		case len(candidates) == 1:
+			activeKid = candidates[0]
+		case len(candidates) == 0 && set.keys[secretKid] != nil:
+			activeKid = secretKid
+		default:
+			return fmt.Errorf("JWT_ACTIVE_KID must be set when several signing keys are present")
+		}
+	}
+
+	active, ok := set.keys[activeKid]
+	if !ok || active.private == nil {
+		return fmt.Errorf("no private key for JWT_ACTIVE_KID %q", activeKid)
+	}
+	set.active = active
+
+	keys = set
+	return nil
+}
+
+func (set *keySet) loadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		isPublic := strings.HasSuffix(name, ".pub.pem")
+		kid := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading JWT key %s: %v", path, err)
+		}
+
+		key, err := parseKey(kid, data, isPublic)
+		if err != nil {
+			return fmt.Errorf("error parsing JWT key %s: %v", path, err)
+		}
+
+		if existing, ok := set.keys[kid]; ok && existing.private != nil {
+			continue
+		}
+		set.keys[kid] = key
+	}
+
+	return nil
+}
+
+func parseKey(kid string, data []byte, isPublic bool) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if isPublic {
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		method, err := methodForKey(public)
+		if err != nil {
+			return nil, err
+		}
+		return &signingKey{kid: kid, method: method, public: public}, nil
+	}
+
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var public interface{}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &k.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+
+	method, err := methodForKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingKey{kid: kid, method: method, private: private, public: public}, nil
+}
+
+func methodForKey(public interface{}) (jwt.SigningMethod, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+// sign issues a token with the active key and records its kid in the header.
+func sign(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		return "", fmt.Errorf("JWT keys are not loaded")
+	}
+
+	jwtToken := jwt.NewWithClaims(keys.active.method, claims)
+	jwtToken.Header["kid"] = keys.active.kid
+	return jwtToken.SignedString(keys.active.private)
+}
+
+// keyFunc resolves the verification key from the token's kid and refuses
+// tokens whose algorithm does not match that key.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("JWT keys are not loaded")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = secretKid
+	}
+
+	key, ok := keys.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// JWKS returns the public keys that other services can use to verify tokens
+// issued by this one. Symmetric keys are never included.
+func JWKS() models.JwksGet200Response {
+	response := models.JwksGet200Response{Keys: []models.Jwk{}}
+	if keys == nil {
+		return response
+	}
+
+	kids := make([]string, 0, len(keys.keys))
+	for kid := range keys.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := keys.keys[kid]
+		jwk := models.Jwk{
+			Kid: kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+
+		switch k := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (k.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = k.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+
+		response.Keys = append(response.Keys, jwk)
+	}
+
+	return response
+}
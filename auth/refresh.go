@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"avito-backend-bootcamp/env"
+)
+
+var refreshTokenTTL = env.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+
+type RefreshToken struct {
+	Token     string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// NewRefreshToken generates an opaque refresh token. Only its hash is meant to
+// be stored, so a leaked database does not leak usable tokens.
+func NewRefreshToken() (*RefreshToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return &RefreshToken{
+		Token:     token,
+		Hash:      HashRefreshToken(token),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}, nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,33 +1,41 @@
 package auth
 
 import (
-	"os"
+	"context"
+	"fmt"
 	"time"
 
+	"avito-backend-bootcamp/env"
+
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
-var jwtSecretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
+var accessTokenTTL = env.Duration("ACCESS_TOKEN_TTL", 15*time.Minute)
 
 type Claims struct {
+	UserID   int    `json:"user_id"`
 	Email    string `json:"email"`
 	UserType string `json:"user_type"`
 	jwt.StandardClaims
 }
 
-func GenerateJwtToken(email, userType string) (string, error) {
-	expTime := time.Now().Add(72 * time.Hour)
+func GenerateJwtToken(userID int, email, userType string) (string, error) {
+	now := time.Now()
+	expTime := now.Add(accessTokenTTL)
 
 	claims := &Claims{
+		UserID:   userID,
 		Email:    email,
 		UserType: userType,
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			IssuedAt:  now.Unix(),
 			ExpiresAt: expTime.Unix(),
 		},
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtTokenString, err := jwtToken.SignedString(jwtSecretKey)
+	jwtTokenString, err := sign(claims)
 
 	if err != nil {
 		return "", err
@@ -36,12 +44,31 @@ func GenerateJwtToken(email, userType string) (string, error) {
 	return jwtTokenString, nil
 }
 
-func ValidateJwtToken(jwtTokenStr string) (*Claims, error) {
+// RevocationList tells whether an access token was revoked before it
+// expired.
+type RevocationList interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RevocationCheckError reports that the revocation list could not be
+// consulted. Unlike the other errors of ValidateJwtToken it says nothing about
+// the token itself.
+type RevocationCheckError struct {
+	Err error
+}
+
+func (e *RevocationCheckError) Error() string {
+	return "checking token revocation: " + e.Err.Error()
+}
+
+func (e *RevocationCheckError) Unwrap() error {
+	return e.Err
+}
+
+func ValidateJwtToken(ctx context.Context, jwtTokenStr string, revocations RevocationList) (*Claims, error) {
 	claims := &Claims{}
 
-	jwtToken, err := jwt.ParseWithClaims(jwtTokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecretKey, nil
-	})
+	jwtToken, err := jwt.ParseWithClaims(jwtTokenStr, claims, keyFunc)
 
 	if err != nil {
 		return nil, err
@@ -51,5 +78,26 @@ func ValidateJwtToken(jwtTokenStr string) (*Claims, error) {
 		return nil, err
 	}
 
+	if claims.Audience != "" {
+		return nil, fmt.Errorf("token is not an access token")
+	}
+
+	if claims.Id == "" {
+		return nil, fmt.Errorf("token has no jti")
+	}
+
+	if claims.UserID == 0 {
+		return nil, fmt.Errorf("token has no user id")
+	}
+
+	revoked, err := revocations.IsTokenRevoked(ctx, claims.Id)
+	if err != nil {
+		return nil, &RevocationCheckError{Err: err}
+	}
+
+	if revoked {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+
 	return claims, nil
 }
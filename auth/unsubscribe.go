@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"avito-backend-bootcamp/env"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const unsubscribeAudience = "unsubscribe"
+
+var unsubscribeTokenTTL = env.Duration("UNSUBSCRIBE_TOKEN_TTL", 90*24*time.Hour)
+
+type UnsubscribeClaims struct {
+	SubscriptionId int32  `json:"subscription_id"`
+	Email          string `json:"email"`
+	jwt.StandardClaims
+}
+
+// GenerateUnsubscribeToken signs a token that lets the holder of the email
+// cancel a single subscription without logging in. Every token gets its own
+// id and expires after UNSUBSCRIBE_TOKEN_TTL, so a leaked link stops working
+// eventually.
+func GenerateUnsubscribeToken(subscriptionId int32, email string) (string, error) {
+	now := time.Now()
+
+	claims := &UnsubscribeClaims{
+		SubscriptionId: subscriptionId,
+		Email:          email,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Audience:  unsubscribeAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(unsubscribeTokenTTL).Unix(),
+		},
+	}
+
+	return sign(claims)
+}
+
+func ValidateUnsubscribeToken(jwtTokenStr string) (*UnsubscribeClaims, error) {
+	claims := &UnsubscribeClaims{}
+
+	jwtToken, err := jwt.ParseWithClaims(jwtTokenStr, claims, keyFunc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !jwtToken.Valid || !claims.VerifyAudience(unsubscribeAudience, true) {
+		return nil, fmt.Errorf("token is not an unsubscribe token")
+	}
+
+	// Links sent before tokens expired would otherwise work forever.
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("unsubscribe token has no expiry")
+	}
+
+	return claims, nil
+}
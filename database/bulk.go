@@ -0,0 +1,105 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// errBatchFailed rolls back an atomic batch in which some update failed.
+var errBatchFailed = errors.New("batch has failed updates")
+
+// ErrNotAttempted is the result of the updates of a best-effort batch that
+// follow one the database failed on.
+var ErrNotAttempted = errors.New("update not attempted")
+
+type FlatStatusUpdate struct {
+	Id     int32
+	Status models.Status
+}
+
+// FlatStatusUpdateResult is the outcome of one FlatStatusUpdate. Err holds
+// one of the flat errors of this package, such as ErrFlatClaimed, or in
+// best-effort mode the storage error the update failed with, or
+// ErrNotAttempted.
+type FlatStatusUpdateResult struct {
+	Flat *models.Flat
+	Err  error
+}
+
+// UpdateFlatStatuses applies updates with the same rules as UpdateFlatStatus
+// and returns one result per update, in order.
+//
+// In atomic mode all updates share one transaction, which is committed only
+// if every update succeeded, so a single failed result means nothing was
+// applied. In best-effort mode every update is committed on its own, with
+// its own query timeout; the batch stops at the first update the database
+// fails on, so that the results still tell which updates were applied.
+func (p *Postgres) UpdateFlatStatuses(ctx context.Context, updates []FlatStatusUpdate, moderatorID int, reason *string, lease time.Duration, atomic bool) ([]FlatStatusUpdateResult, error) {
+	results := make([]FlatStatusUpdateResult, len(updates))
+
+	if !atomic {
+		for i, update := range updates {
+			flat, err := p.UpdateFlatStatus(ctx, update.Id, string(update.Status), moderatorID, reason, lease)
+			results[i] = FlatStatusUpdateResult{Flat: flat, Err: err}
+			if err != nil && !isFlatError(err) {
+				notAttempted(results[i+1:])
+				break
+			}
+		}
+		return results, nil
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	// Rows are locked in id order so that two atomic batches touching the
+	// same flats cannot deadlock.
+	order := make([]int, len(updates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return updates[order[a]].Id < updates[order[b]].Id
+	})
+
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		failed := false
+		for _, i := range order {
+			flat, err := updateFlatStatus(ctx, tx, updates[i].Id, string(updates[i].Status), moderatorID, reason, lease)
+			if err != nil && !isFlatError(err) {
+				return err
+			}
+			results[i] = FlatStatusUpdateResult{Flat: flat, Err: err}
+			failed = failed || err != nil
+		}
+
+		if failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if err != nil && err != errBatchFailed {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func notAttempted(results []FlatStatusUpdateResult) {
+	for i := range results {
+		results[i] = FlatStatusUpdateResult{Err: ErrNotAttempted}
+	}
+}
+
+func isFlatError(err error) bool {
+	switch err {
+	case ErrFlatNotFound, ErrFlatClaimed, ErrFlatNotClaimed, ErrInvalidStatusTransition:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"avito-backend-bootcamp/env"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Config holds the connection pool settings and the query timeout.
+type Config struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// QueryTimeout bounds every repository call, be it a single query or a
+	// whole transaction. Zero disables the limit.
+	QueryTimeout time.Duration
+}
+
+// ConfigFromEnv reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME and DB_QUERY_TIMEOUT. Durations
+// use Go syntax, e.g. "30s" or "5m".
+func ConfigFromEnv() Config {
+	return Config{
+		MaxOpenConns:    env.Int("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    env.Int("DB_MAX_IDLE_CONNS", 25),
+		ConnMaxLifetime: env.OptionalDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: env.OptionalDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		QueryTimeout:    env.OptionalDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+	}
+}
+
+func (config Config) apply(db *sql.DB) {
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+}
+
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.queryTimeout)
+}
+
+// IsCanceled reports whether err means a call was cut short by its context,
+// either because the caller gave up or because the query timed out.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		pqErrorCode(err) == queryCanceled
+}
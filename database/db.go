@@ -3,7 +3,9 @@ package database
 import (
 	"avito-backend-bootcamp/migrations"
 	"avito-backend-bootcamp/models"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,65 +14,108 @@ import (
 	_ "github.com/lib/pq"
 )
 
-var DB *sql.DB
+var (
+	ErrFlatNotFound   = errors.New("flat not found")
+	ErrFlatClaimed    = errors.New("flat is already under moderation")
+	ErrFlatNotClaimed = errors.New("flat is not under moderation by this moderator")
 
-func InitDB() error {
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
+	ErrInvalidStatusTransition = errors.New("status transition is not allowed")
 
-	connectionStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+	ErrUserExists   = errors.New("user with this email already exists")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// flatColumns and houseColumns list the columns read by scanFlat and
+// scanHouse, in order.
+const (
+	flatColumns  = "id, house_id, flat_number, price, currency, rooms, status, created_at, created_by, moderated_by, claimed_by, claim_expires_at, deleted_at"
+	houseColumns = "id, address, year, developer, created_at, update_at, created_by, archived_at"
+)
 
-	var err error
-	DB, err = sql.Open("postgres", connectionStr)
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFlat(row rowScanner, flat *models.Flat) error {
+	return row.Scan(&flat.Id, &flat.HouseId, &flat.FlatNumber, &flat.Price, &flat.Currency, &flat.Rooms, &flat.Status, &flat.CreatedAt,
+		&flat.CreatedBy, &flat.ModeratedBy, &flat.ClaimedBy, &flat.ClaimExpiresAt, &flat.DeletedAt)
+}
+
+func scanHouse(row rowScanner, house *models.House) error {
+	return row.Scan(&house.Id, &house.Address, &house.Year, &house.Developer, &house.CreatedAt, &house.UpdateAt,
+		&house.CreatedBy, &house.ArchivedAt)
+}
+
+// Postgres implements every repository of this package on top of a Postgres
+// connection pool.
+type Postgres struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgres(db *sql.DB, config Config) *Postgres {
+	return &Postgres{db: db, queryTimeout: config.QueryTimeout}
+}
+
+// Open connects to the database named by DB_NAME and applies pending
+// migrations.
+func Open(config Config) (*sql.DB, error) {
+	return openAndMigrate(os.Getenv("DB_NAME"), config)
+}
+
+// OpenTest is Open for the database named by TEST_DB_NAME.
+func OpenTest(config Config) (*sql.DB, error) {
+	return openAndMigrate(os.Getenv("TEST_DB_NAME"), config)
+}
+
+// Connect connects to the database named by DB_NAME without touching its
+// schema.
+func Connect(config Config) (*sql.DB, error) {
+	return open(os.Getenv("DB_NAME"), config)
+}
+
+func openAndMigrate(dbName string, config Config) (*sql.DB, error) {
+	db, err := open(dbName, config)
 	if err != nil {
-		return fmt.Errorf("error opening database: %v", err)
+		return nil, err
 	}
 
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("error connecting to the database: %v", err)
+	if err := migrations.Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating the database %s: %v", dbName, err)
 	}
-
-	migrations.Migrate(DB)
-	return nil
+	return db, nil
 }
 
-func InitTestDB() error {
+func open(dbName string, config Config) (*sql.DB, error) {
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
-	testDbName := os.Getenv("TEST_DB_NAME")
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
 
 	connectionStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, testDbName)
+		dbHost, dbPort, dbUser, dbPassword, dbName)
 
-	var err error
-	DB, err = sql.Open("postgres", connectionStr)
+	db, err := sql.Open("postgres", connectionStr)
 	if err != nil {
-		return fmt.Errorf("error opening test database: %v", err)
+		return nil, fmt.Errorf("error opening database %s: %v", dbName, err)
 	}
+	config.apply(db)
 
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("error connecting to the test database: %v", err)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to the database %s: %v", dbName, err)
 	}
 
-	migrations.Migrate(DB)
-	return nil
+	return db, nil
 }
 
-func ClearTestDB() error {
-	if DB == nil {
-		return fmt.Errorf("database connection is not initialized")
-	}
-
-	tables := []string{"flats", "houses", "users"}
+// ClearTestDB empties every table. It is meant for test databases only.
+func ClearTestDB(db *sql.DB) error {
+	tables := []string{"flat_prices", "flat_status_history", "revoked_tokens", "refresh_tokens", "notification_outbox", "subscriptions", "flats", "houses", "users"}
 	for _, table := range tables {
 		query := fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE;", table)
-		_, err := DB.Exec(query)
+		_, err := db.Exec(query)
 		if err != nil {
 			return fmt.Errorf("error clearing table %s: %v", table, err)
 		}
@@ -78,14 +123,16 @@ func ClearTestDB() error {
 	return nil
 }
 
-func CreateUser(user *models.User) error {
-	if DB == nil {
-		return fmt.Errorf("database connection is not initialized")
-	}
+func (p *Postgres) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	query := "INSERT INTO users (email, password, user_type) VALUES ($1, $2, $3) RETURNING id"
-	err := DB.QueryRow(query, user.Email, user.Password, user.UserType).Scan(&user.ID)
+	err := p.db.QueryRowContext(ctx, query, user.Email, user.Password, user.UserType).Scan(&user.ID)
 
+	if pqErrorCode(err) == uniqueViolation {
+		return ErrUserExists
+	}
 	if err != nil {
 		log.Printf("Error creating user: %v\n", err)
 		return err
@@ -94,79 +141,264 @@ func CreateUser(user *models.User) error {
 	return nil
 }
 
-func CreateHouse(house *models.House) error {
-	if DB == nil {
-		return fmt.Errorf("database connection is not initialized")
-	}
+func (p *Postgres) CreateHouse(ctx context.Context, house *models.House) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	query := "INSERT INTO houses (address, year, developer, created_at, update_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
-	err := DB.QueryRow(query, house.Address, house.Year, house.Developer, house.CreatedAt, house.UpdateAt).Scan(&house.Id)
+	query := "INSERT INTO houses (address, year, developer, created_at, update_at, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+	err := p.db.QueryRowContext(ctx, query, house.Address, house.Year, house.Developer, house.CreatedAt, house.UpdateAt, house.CreatedBy).Scan(&house.Id)
 	if err != nil {
-		log.Printf("Error creating user: %v\n", err)
+		if known := constraintError(err); known != nil {
+			return known
+		}
+		log.Printf("Error creating house: %v\n", err)
 		return err
 	}
 
 	return nil
 }
 
-func CreateFlat(flat *models.Flat) error {
-	if DB == nil {
-		return fmt.Errorf("database connection is not initialized")
+// CreateFlat inserts flat with the status it already carries and bumps its
+// house's update_at in the same transaction. A flat that is not "created"
+// was decided by auto-moderation: the decision is recorded in the status
+// history without an actor, and an approval notifies the house's
+// subscribers just like a moderator's would. ErrHouseNotFound,
+// ErrUserNotFound and ErrFlatExists report a missing house or author and a
+// taken flat number, and ErrInvalidStatusTransition a status auto-moderation
+// may not decide on.
+func (p *Postgres) CreateFlat(ctx context.Context, flat *models.Flat, reason *string) error {
+	if !canCreateWithStatus(flat.Status) {
+		return ErrInvalidStatusTransition
 	}
 
-	query := "INSERT INTO flats (house_id, flat_number, price, rooms, status) VALUES ($1, $2, $3, $4, $5) RETURNING id"
-	err := DB.QueryRow(query, flat.HouseId, flat.FlatNumber, flat.Price, flat.Rooms, flat.Status).Scan(&flat.Id)
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := touchHouse(ctx, tx, flat.HouseId); err != nil {
+			return err
+		}
+		return createFlat(ctx, tx, flat, reason)
+	})
+}
+
+// canCreateWithStatus reports whether a new flat may start in status: either
+// "created", or a decision auto-moderation is allowed to make.
+func canCreateWithStatus(status models.Status) bool {
+	return status == models.CREATED || models.CanTransition(models.ACTOR_RULES, models.CREATED, status)
+}
+
+func createFlat(ctx context.Context, tx *sql.Tx, flat *models.Flat, reason *string) error {
+	flat.CreatedAt = time.Now()
+	query := "INSERT INTO flats (house_id, flat_number, price, currency, rooms, status, created_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"
+	err := tx.QueryRowContext(ctx, query, flat.HouseId, flat.FlatNumber, flat.Price, flat.Currency, flat.Rooms, flat.Status, flat.CreatedAt, flat.CreatedBy).Scan(&flat.Id)
 	if err != nil {
+		if known := constraintError(err); known != nil {
+			return known
+		}
 		log.Printf("Error creating flat: %v\n", err)
 		return err
 	}
+
+	err = insertFlatPrice(ctx, tx, &models.FlatPrice{
+		FlatId:    flat.Id,
+		Price:     flat.Price,
+		Currency:  flat.Currency,
+		ChangedBy: flat.CreatedBy,
+		ChangedAt: flat.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Error recording flat price: %v\n", err)
+		return err
+	}
+
+	if flat.Status != models.CREATED {
+		err = insertFlatStatusChange(ctx, tx, &models.FlatStatusChange{
+			FlatId:         flat.Id,
+			PreviousStatus: models.CREATED,
+			NewStatus:      flat.Status,
+			Reason:         reason,
+			ChangedAt:      flat.CreatedAt,
+		})
+		if err != nil {
+			log.Printf("Error recording flat status change: %v\n", err)
+			return err
+		}
+	}
+
+	if flat.Status == models.APPROVED {
+		if err := enqueueFlatApprovedNotifications(ctx, tx, flat); err != nil {
+			log.Printf("Error enqueueing notifications: %v\n", err)
+			return err
+		}
+	}
+
 	return nil
 }
 
-func UpdateHouse(houseId int32) error {
-	if DB == nil {
-		return fmt.Errorf("database connection is not initialized")
-	}
-	query := "UPDATE houses SET update_at = $1 WHERE id = $2"
-	_, err := DB.Exec(query, time.Now(), houseId)
+// touchHouse bumps the house's update_at, or returns ErrHouseNotFound. The
+// row stays locked until tx ends.
+func touchHouse(ctx context.Context, tx *sql.Tx, houseId int32) error {
+	result, err := tx.ExecContext(ctx, "UPDATE houses SET update_at = $1 WHERE id = $2", time.Now(), houseId)
 	if err != nil {
 		log.Printf("Error updating house: %v\n", err)
 		return err
 	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrHouseNotFound
+	}
 	return nil
 }
 
-func UpdateFlatStatus(id int32, status string) (*models.Flat, error) {
-	if DB == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+// UpdateFlatStatus moves a flat to status on behalf of a moderator. The move
+// must be allowed by models.StatusTransitions, otherwise
+// ErrInvalidStatusTransition is returned. Every change is recorded in the
+// flat's status history together with the moderator and reason.
+//
+// Moderation is claim based: putting a flat "on moderation" assigns it to
+// the moderator for lease, and only that moderator may move it on while the
+// claim is live. Both rules are part of the UPDATE statements, so concurrent
+// moderators are serialized by Postgres rather than by this process.
+func (p *Postgres) UpdateFlatStatus(ctx context.Context, id int32, status string, moderatorID int, reason *string, lease time.Duration) (*models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var flat *models.Flat
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		flat, err = updateFlatStatus(ctx, tx, id, status, moderatorID, reason, lease)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return flat, nil
+}
+
+// updateFlatStatus is UpdateFlatStatus within a transaction owned by the
+// caller.
+func updateFlatStatus(ctx context.Context, tx *sql.Tx, id int32, status string, moderatorID int, reason *string, lease time.Duration) (*models.Flat, error) {
+	var previousStatus models.Status
+	var claimExpiresAt *time.Time
+	query := "SELECT status, claim_expires_at FROM flats WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+	err := tx.QueryRowContext(ctx, query, id).Scan(&previousStatus, &claimExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFlatNotFound
+		}
+		log.Printf("Error locking flat: %v\n", err)
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// A flat whose claim has expired is back in the queue even if the
+	// release job has not caught up with it yet.
+	currentStatus := previousStatus
+	if currentStatus == models.ON_MODERATION && (claimExpiresAt == nil || !claimExpiresAt.After(now)) {
+		currentStatus = models.CREATED
+	}
+
+	if currentStatus == models.ON_MODERATION && models.Status(status) == models.ON_MODERATION {
+		return nil, ErrFlatClaimed
+	}
+
+	if !models.CanTransition(models.ACTOR_MODERATOR, currentStatus, models.Status(status)) {
+		return nil, ErrInvalidStatusTransition
 	}
 
-	query := "UPDATE flats SET status = $1 WHERE id = $2 RETURNING id, house_id, price, rooms, status"
-	row := DB.QueryRow(query, status, id)
+	var row *sql.Row
+	if models.Status(status) == models.ON_MODERATION {
+		query = `UPDATE flats SET status = $1, moderated_by = $2, claimed_by = $2, claim_expires_at = $3
+			WHERE id = $4 AND NOT (status = $1 AND COALESCE(claim_expires_at > $5, FALSE))
+			RETURNING ` + flatColumns
+		row = tx.QueryRowContext(ctx, query, status, moderatorID, now.Add(lease), id, now)
+	} else {
+		query = `UPDATE flats SET status = $1, moderated_by = $2, claimed_by = NULL, claim_expires_at = NULL
+			WHERE id = $3 AND status = $4 AND claimed_by = $2 AND claim_expires_at > $5
+			RETURNING ` + flatColumns
+		row = tx.QueryRowContext(ctx, query, status, moderatorID, id, models.ON_MODERATION, now)
+	}
 
 	var flat models.Flat
-	err := row.Scan(&flat.Id, &flat.HouseId, &flat.Price, &flat.Rooms, &flat.Status)
+	err = scanFlat(row, &flat)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			if models.Status(status) == models.ON_MODERATION {
+				return nil, ErrFlatClaimed
+			}
+			return nil, ErrFlatNotClaimed
+		}
+		if known := constraintError(err); known != nil {
+			return nil, known
+		}
 		log.Printf("Error updating flat status: %v\n", err)
 		return nil, err
 	}
 
+	err = insertFlatStatusChange(ctx, tx, &models.FlatStatusChange{
+		FlatId:         flat.Id,
+		PreviousStatus: previousStatus,
+		NewStatus:      flat.Status,
+		ActorId:        &moderatorID,
+		Reason:         reason,
+		ChangedAt:      now,
+	})
+	if err != nil {
+		log.Printf("Error recording flat status change: %v\n", err)
+		return nil, err
+	}
+
+	if previousStatus != models.APPROVED && flat.Status == models.APPROVED {
+		if err := enqueueFlatApprovedNotifications(ctx, tx, &flat); err != nil {
+			log.Printf("Error enqueueing notifications: %v\n", err)
+			return nil, err
+		}
+	}
+
 	return &flat, nil
 }
 
-func GetFlatByID(id int32) (*models.Flat, error) {
-	if DB == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+// ReleaseExpiredClaims returns flats whose moderation lease ran out to the
+// "created" status so that any moderator can pick them up again.
+func (p *Postgres) ReleaseExpiredClaims(ctx context.Context) (int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `WITH released AS (
+			UPDATE flats SET status = $1, claimed_by = NULL, claim_expires_at = NULL
+			WHERE status = $2 AND (claim_expires_at IS NULL OR claim_expires_at <= $3) AND deleted_at IS NULL
+			RETURNING id
+		)
+		INSERT INTO flat_status_history (flat_id, previous_status, new_status, reason, changed_at)
+		SELECT id, $2, $1, $4, $3 FROM released`
+	result, err := p.db.ExecContext(ctx, query, models.CREATED, models.ON_MODERATION, time.Now(), "moderation lease expired")
+	if err != nil {
+		log.Printf("Error releasing expired claims: %v\n", err)
+		return 0, err
 	}
 
-	query := "SELECT id, house_id, flat_number, price, rooms, status FROM flats WHERE id = $1"
-	row := DB.QueryRow(query, id)
+	return result.RowsAffected()
+}
+
+func (p *Postgres) GetFlatByID(ctx context.Context, id int32) (*models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + flatColumns + " FROM flats WHERE id = $1 AND deleted_at IS NULL"
+	row := p.db.QueryRowContext(ctx, query, id)
 
 	var flat models.Flat
-	err := row.Scan(&flat.Id, &flat.HouseId, &flat.FlatNumber, &flat.Price, &flat.Rooms, &flat.Status)
+	err := scanFlat(row, &flat)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("flat not found")
+			return nil, ErrFlatNotFound
 		}
 		return nil, err
 	}
@@ -174,14 +406,13 @@ func GetFlatByID(id int32) (*models.Flat, error) {
 	return &flat, nil
 }
 
-func GetUserByEmail(email string) (*models.User, error) {
-	if DB == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
-	}
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	user := &models.User{}
 	query := "SELECT id, email, password, user_type FROM users WHERE email = $1"
-	row := DB.QueryRow(query, email)
+	row := p.db.QueryRowContext(ctx, query, email)
 
 	if err := row.Scan(&user.ID, &user.Email, &user.Password, &user.UserType); err != nil {
 		if err == sql.ErrNoRows {
@@ -194,37 +425,102 @@ func GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
-func GetFlatsByHouseID(houseID int, status string) ([]models.Flat, error) {
-	if DB == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+// EnsureUser returns the user with the given email, creating it first if it
+// does not exist yet. An existing user is returned as is.
+func (p *Postgres) EnsureUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO users (email, password, user_type) VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING id, password, user_type`
+	err := p.db.QueryRowContext(ctx, query, user.Email, user.Password, user.UserType).Scan(&user.ID, &user.Password, &user.UserType)
+	if err != nil {
+		log.Printf("Error ensuring user: %v\n", err)
+		return err
 	}
 
-	var rows *sql.Rows
-	var err error
+	return nil
+}
 
-	if status == "all" {
-		query := "SELECT id, house_id, flat_number, price, rooms, status FROM flats WHERE house_id = $1"
-		rows, err = DB.Query(query, houseID)
-	} else {
-		query := "SELECT id, house_id, flat_number, price, rooms, status FROM flats WHERE house_id = $1 AND status = $2"
-		rows, err = DB.Query(query, houseID, status)
+func (p *Postgres) GetHouseByID(ctx context.Context, id int32) (*models.House, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	house := &models.House{}
+	query := "SELECT " + houseColumns + " FROM houses WHERE id = $1"
+	row := p.db.QueryRowContext(ctx, query, id)
+
+	if err := scanHouse(row, house); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
 	}
 
+	return house, nil
+}
+
+func (p *Postgres) CreateSubscription(ctx context.Context, subscription *models.Subscription) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO subscriptions (house_id, email, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (house_id, email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING id, created_at`
+	err := p.db.QueryRowContext(ctx, query, subscription.HouseId, subscription.Email, time.Now()).Scan(&subscription.Id, &subscription.CreatedAt)
 	if err != nil {
-		log.Printf("Error fetching flats: %v\n", err)
+		if known := constraintError(err); known != nil {
+			return known
+		}
+		log.Printf("Error creating subscription: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetSubscriptionByID(ctx context.Context, id int32) (*models.Subscription, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	subscription := &models.Subscription{}
+	query := "SELECT id, house_id, email, created_at FROM subscriptions WHERE id = $1"
+	row := p.db.QueryRowContext(ctx, query, id)
+
+	if err := row.Scan(&subscription.Id, &subscription.HouseId, &subscription.Email, &subscription.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (p *Postgres) GetSubscriptionsByEmail(ctx context.Context, email string) ([]models.Subscription, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, house_id, email, created_at FROM subscriptions WHERE email = $1 ORDER BY id"
+	rows, err := p.db.QueryContext(ctx, query, email)
+	if err != nil {
+		log.Printf("Error fetching subscriptions: %v\n", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var flats []models.Flat
+	subscriptions := []models.Subscription{}
 	for rows.Next() {
-		var flat models.Flat
-		err := rows.Scan(&flat.Id, &flat.HouseId, &flat.FlatNumber, &flat.Price, &flat.Rooms, &flat.Status)
+		var subscription models.Subscription
+		err := rows.Scan(&subscription.Id, &subscription.HouseId, &subscription.Email, &subscription.CreatedAt)
 		if err != nil {
-			log.Printf("Error scanning flat: %v\n", err)
+			log.Printf("Error scanning subscription: %v\n", err)
 			return nil, err
 		}
-		flats = append(flats, flat)
+		subscriptions = append(subscriptions, subscription)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -232,5 +528,27 @@ func GetFlatsByHouseID(houseID int, status string) ([]models.Flat, error) {
 		return nil, err
 	}
 
-	return flats, nil
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes the subscription together with any of its
+// notifications that have not been delivered yet.
+func (p *Postgres) DeleteSubscription(ctx context.Context, id int32) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.WithTx(ctx, func(tx *sql.Tx) error {
+		query := "DELETE FROM notification_outbox WHERE status = $1 AND kind = $2 AND (payload->>'subscription_id')::int = $3"
+		if _, err := tx.ExecContext(ctx, query, models.NOTIFICATION_PENDING, models.FLAT_APPROVED, id); err != nil {
+			log.Printf("Error deleting pending notifications: %v\n", err)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM subscriptions WHERE id = $1", id); err != nil {
+			log.Printf("Error deleting subscription: %v\n", err)
+			return err
+		}
+
+		return nil
+	})
 }
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// FlatEdit changes a flat's attributes. Nil fields are left alone.
+type FlatEdit struct {
+	FlatNumber *int32
+	Price      *int64
+	Currency   *string
+	Rooms      *int32
+	// Remoderate sends the flat back to the moderation queue when the edit
+	// changes anything, which is what happens to edits by the flat's author.
+	// Moderators' edits leave the status alone.
+	Remoderate bool
+}
+
+const remoderationReason = "edited by author"
+
+// apply returns flat with the edit applied and whether anything changed.
+// Sending the flat back to moderation must be allowed by
+// models.StatusTransitions, otherwise ErrInvalidStatusTransition is returned.
+func (edit FlatEdit) apply(flat models.Flat) (models.Flat, bool, error) {
+	edited := flat
+	if edit.FlatNumber != nil {
+		edited.FlatNumber = *edit.FlatNumber
+	}
+	if edit.Price != nil {
+		edited.Price = *edit.Price
+	}
+	if edit.Currency != nil {
+		edited.Currency = *edit.Currency
+	}
+	if edit.Rooms != nil {
+		edited.Rooms = *edit.Rooms
+	}
+
+	changed := edited.FlatNumber != flat.FlatNumber || edited.Price != flat.Price || edited.Currency != flat.Currency ||
+		edited.Rooms != flat.Rooms
+	if changed && edit.Remoderate && flat.Status != models.CREATED {
+		if !models.CanTransition(models.ACTOR_AUTHOR, flat.Status, models.CREATED) {
+			return flat, false, ErrInvalidStatusTransition
+		}
+		edited.Status = models.CREATED
+		edited.ClaimedBy = nil
+		edited.ClaimExpiresAt = nil
+	}
+	return edited, changed, nil
+}
+
+// EditFlat applies edit on behalf of actorID and bumps the house's
+// update_at. A new price is added to the flat's price history, and a flat
+// sent back to moderation gets an entry in its status history.
+// ErrFlatNotFound and ErrFlatExists report a missing flat and a taken flat
+// number, ErrInvalidStatusTransition a flat its author may not reopen.
+func (p *Postgres) EditFlat(ctx context.Context, id int32, edit FlatEdit, actorID int) (*models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var flat models.Flat
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		query := "SELECT " + flatColumns + " FROM flats WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+		if err := scanFlat(tx.QueryRowContext(ctx, query, id), &flat); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrFlatNotFound
+			}
+			log.Printf("Error locking flat: %v\n", err)
+			return err
+		}
+
+		edited, changed, err := edit.apply(flat)
+		if err != nil || !changed {
+			return err
+		}
+
+		if err := touchHouse(ctx, tx, flat.HouseId); err != nil {
+			return err
+		}
+
+		query = `UPDATE flats SET flat_number = $1, price = $2, currency = $3, rooms = $4, status = $5, claimed_by = $6,
+			claim_expires_at = $7 WHERE id = $8 RETURNING ` + flatColumns
+		row := tx.QueryRowContext(ctx, query, edited.FlatNumber, edited.Price, edited.Currency, edited.Rooms, edited.Status,
+			edited.ClaimedBy, edited.ClaimExpiresAt, id)
+		previous := flat
+		if err := scanFlat(row, &flat); err != nil {
+			if known := constraintError(err); known != nil {
+				return known
+			}
+			log.Printf("Error editing flat: %v\n", err)
+			return err
+		}
+
+		now := time.Now()
+		if flat.Price != previous.Price || flat.Currency != previous.Currency {
+			err := insertFlatPrice(ctx, tx, &models.FlatPrice{
+				FlatId:    flat.Id,
+				Price:     flat.Price,
+				Currency:  flat.Currency,
+				ChangedBy: &actorID,
+				ChangedAt: now,
+			})
+			if err != nil {
+				log.Printf("Error recording flat price: %v\n", err)
+				return err
+			}
+		}
+
+		if flat.Status == previous.Status {
+			return nil
+		}
+
+		reason := remoderationReason
+		err = insertFlatStatusChange(ctx, tx, &models.FlatStatusChange{
+			FlatId:         flat.Id,
+			PreviousStatus: previous.Status,
+			NewStatus:      flat.Status,
+			ActorId:        &actorID,
+			Reason:         &reason,
+			ChangedAt:      now,
+		})
+		if err != nil {
+			log.Printf("Error recording flat status change: %v\n", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &flat, nil
+}
+
+// DeleteFlat soft-deletes a flat: it keeps its row and history but is no
+// longer returned by any query, and its number may be reused.
+func (p *Postgres) DeleteFlat(ctx context.Context, id int32) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.WithTx(ctx, func(tx *sql.Tx) error {
+		var houseId int32
+		query := `UPDATE flats SET deleted_at = $1, claimed_by = NULL, claim_expires_at = NULL
+			WHERE id = $2 AND deleted_at IS NULL RETURNING house_id`
+		if err := tx.QueryRowContext(ctx, query, time.Now(), id).Scan(&houseId); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrFlatNotFound
+			}
+			log.Printf("Error deleting flat: %v\n", err)
+			return err
+		}
+
+		return touchHouse(ctx, tx, houseId)
+	})
+}
+
+func (m *Memory) EditFlat(ctx context.Context, id int32, edit FlatEdit, actorID int) (*models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flat, ok := m.flats[id]
+	if !ok {
+		return nil, ErrFlatNotFound
+	}
+	if !m.userExists(&actorID) {
+		return nil, ErrUserNotFound
+	}
+
+	edited, changed, err := edit.apply(flat)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return &flat, nil
+	}
+
+	key := flatKey{edited.HouseId, edited.FlatNumber}
+	if edited.Status != models.DECLINED && m.flatNumberTaken(key, id) {
+		return nil, ErrFlatExists
+	}
+
+	now := time.Now()
+	house := m.houses[flat.HouseId]
+	house.UpdateAt = now
+	m.houses[house.Id] = house
+
+	m.releaseFlatNumber(flat)
+	if edited.Status != models.DECLINED {
+		m.flatIdByKey[key] = id
+	}
+	m.flats[id] = edited
+
+	actor := actorID
+	if edited.Price != flat.Price || edited.Currency != flat.Currency {
+		m.recordPrice(models.FlatPrice{
+			FlatId:    id,
+			Price:     edited.Price,
+			Currency:  edited.Currency,
+			ChangedBy: &actor,
+			ChangedAt: now,
+		})
+	}
+
+	if edited.Status != flat.Status {
+		reason := remoderationReason
+		m.recordStatusChange(models.FlatStatusChange{
+			FlatId:         id,
+			PreviousStatus: flat.Status,
+			NewStatus:      edited.Status,
+			ActorId:        &actor,
+			Reason:         &reason,
+			ChangedAt:      now,
+		})
+	}
+
+	return &edited, nil
+}
+
+func (m *Memory) DeleteFlat(ctx context.Context, id int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flat, ok := m.flats[id]
+	if !ok {
+		return ErrFlatNotFound
+	}
+
+	now := time.Now()
+	flat.DeletedAt = &now
+	flat.ClaimedBy = nil
+	flat.ClaimExpiresAt = nil
+
+	house := m.houses[flat.HouseId]
+	house.UpdateAt = now
+	m.houses[house.Id] = house
+
+	delete(m.flats, id)
+	m.releaseFlatNumber(flat)
+	m.deletedFlats[id] = flat
+	return nil
+}
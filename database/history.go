@@ -0,0 +1,52 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"log"
+)
+
+func insertFlatStatusChange(ctx context.Context, tx *sql.Tx, change *models.FlatStatusChange) error {
+	query := `INSERT INTO flat_status_history (flat_id, previous_status, new_status, actor_id, reason, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := tx.QueryRowContext(ctx, query, change.FlatId, change.PreviousStatus, change.NewStatus, change.ActorId,
+		change.Reason, change.ChangedAt).Scan(&change.Id)
+	if known := constraintError(err); known != nil {
+		return known
+	}
+	return err
+}
+
+func (p *Postgres) GetFlatStatusHistory(ctx context.Context, flatID int32) ([]models.FlatStatusChange, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, flat_id, previous_status, new_status, actor_id, reason, changed_at
+		FROM flat_status_history WHERE flat_id = $1 ORDER BY changed_at, id`
+	rows, err := p.db.QueryContext(ctx, query, flatID)
+	if err != nil {
+		log.Printf("Error fetching flat status history: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.FlatStatusChange{}
+	for rows.Next() {
+		var change models.FlatStatusChange
+		err := rows.Scan(&change.Id, &change.FlatId, &change.PreviousStatus, &change.NewStatus, &change.ActorId,
+			&change.Reason, &change.ChangedAt)
+		if err != nil {
+			log.Printf("Error scanning flat status change: %v\n", err)
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return history, nil
+}
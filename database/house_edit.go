@@ -0,0 +1,123 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// HouseEdit changes a house's attributes. Nil fields are left alone; an
+// empty Developer clears the developer.
+type HouseEdit struct {
+	Address   *string
+	Year      *int32
+	Developer *string
+}
+
+func (edit HouseEdit) apply(house *models.House) {
+	if edit.Address != nil {
+		house.Address = *edit.Address
+	}
+	if edit.Year != nil {
+		house.Year = *edit.Year
+	}
+	if edit.Developer != nil {
+		if *edit.Developer == "" {
+			house.Developer = nil
+		} else {
+			developer := *edit.Developer
+			house.Developer = &developer
+		}
+	}
+}
+
+// EditHouse applies edit and bumps the house's update_at, or returns
+// ErrHouseNotFound.
+func (p *Postgres) EditHouse(ctx context.Context, id int32, edit HouseEdit) (*models.House, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var house models.House
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		query := "SELECT " + houseColumns + " FROM houses WHERE id = $1 FOR UPDATE"
+		if err := scanHouse(tx.QueryRowContext(ctx, query, id), &house); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrHouseNotFound
+			}
+			log.Printf("Error locking house: %v\n", err)
+			return err
+		}
+
+		edit.apply(&house)
+
+		query = "UPDATE houses SET address = $1, year = $2, developer = $3, update_at = $4 WHERE id = $5 RETURNING " + houseColumns
+		row := tx.QueryRowContext(ctx, query, house.Address, house.Year, house.Developer, time.Now(), id)
+		if err := scanHouse(row, &house); err != nil {
+			log.Printf("Error updating house: %v\n", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &house, nil
+}
+
+// ArchiveHouse hides a house and its flats from clients and bumps its
+// update_at. Archiving an archived house changes nothing. ErrHouseNotFound
+// reports a missing house.
+func (p *Postgres) ArchiveHouse(ctx context.Context, id int32) (*models.House, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	query := "UPDATE houses SET archived_at = COALESCE(archived_at, $1), update_at = CASE WHEN archived_at IS NULL THEN $1 ELSE update_at END WHERE id = $2 RETURNING " + houseColumns
+
+	var house models.House
+	if err := scanHouse(p.db.QueryRowContext(ctx, query, now, id), &house); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHouseNotFound
+		}
+		log.Printf("Error archiving house: %v\n", err)
+		return nil, err
+	}
+
+	return &house, nil
+}
+
+func (m *Memory) EditHouse(ctx context.Context, id int32, edit HouseEdit) (*models.House, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	house, ok := m.houses[id]
+	if !ok {
+		return nil, ErrHouseNotFound
+	}
+
+	edit.apply(&house)
+	house.UpdateAt = time.Now()
+	m.houses[id] = house
+	return &house, nil
+}
+
+func (m *Memory) ArchiveHouse(ctx context.Context, id int32) (*models.House, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	house, ok := m.houses[id]
+	if !ok {
+		return nil, ErrHouseNotFound
+	}
+
+	if house.ArchivedAt == nil {
+		now := time.Now()
+		house.ArchivedAt = &now
+		house.UpdateAt = now
+		m.houses[id] = house
+	}
+	return &house, nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// FlatSort is a column the flats of a house can be ordered by. Ties are
+// always broken by id so that pages never overlap.
+type FlatSort string
+
+const (
+	FlatSortFlatNumber FlatSort = "flat_number"
+	FlatSortPrice      FlatSort = "price"
+	FlatSortRooms      FlatSort = "rooms"
+)
+
+// FlatFilter narrows the flats of a house. Nil fields are not applied.
+type FlatFilter struct {
+	Status   *models.Status
+	Rooms    *int32
+	Currency *string
+	PriceMin *int64
+	PriceMax *int64
+}
+
+type FlatOrder struct {
+	Sort       FlatSort
+	Descending bool
+}
+
+// FlatCursor points just past the last flat of the previous page: Value is
+// that flat's value of the sort column.
+type FlatCursor struct {
+	Value int64
+	Id    int32
+}
+
+// Value returns the flat's value of the sort column.
+func (s FlatSort) Value(flat models.Flat) int64 {
+	switch s {
+	case FlatSortPrice:
+		return flat.Price
+	case FlatSortRooms:
+		return int64(flat.Rooms)
+	default:
+		return int64(flat.FlatNumber)
+	}
+}
+
+func (s FlatSort) column() string {
+	switch s {
+	case FlatSortPrice, FlatSortRooms:
+		return string(s)
+	default:
+		return string(FlatSortFlatNumber)
+	}
+}
+
+// matches reports whether flat passes the filter.
+func (filter FlatFilter) matches(flat models.Flat) bool {
+	if filter.Status != nil && flat.Status != *filter.Status {
+		return false
+	}
+	if filter.Rooms != nil && flat.Rooms != *filter.Rooms {
+		return false
+	}
+	if filter.Currency != nil && flat.Currency != *filter.Currency {
+		return false
+	}
+	if filter.PriceMin != nil && flat.Price < *filter.PriceMin {
+		return false
+	}
+	if filter.PriceMax != nil && flat.Price > *filter.PriceMax {
+		return false
+	}
+	return true
+}
+
+// before reports whether a flat with the given key comes before b in order.
+func (order FlatOrder) before(value int64, id int32, b models.Flat) bool {
+	bValue := order.Sort.Value(b)
+	if value == bValue {
+		if order.Descending {
+			return id > b.Id
+		}
+		return id < b.Id
+	}
+	if order.Descending {
+		return value > bValue
+	}
+	return value < bValue
+}
+
+// GetFlatsByHouseID returns up to limit of the house's flats that pass
+// filter, in order, starting after cursor.
+func (p *Postgres) GetFlatsByHouseID(ctx context.Context, houseID int, filter FlatFilter, order FlatOrder, cursor *FlatCursor, limit int) ([]models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{houseID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"house_id = $1", "deleted_at IS NULL"}
+	if filter.Status != nil {
+		conditions = append(conditions, "status = "+arg(*filter.Status))
+	}
+	if filter.Rooms != nil {
+		conditions = append(conditions, "rooms = "+arg(*filter.Rooms))
+	}
+	if filter.Currency != nil {
+		conditions = append(conditions, "currency = "+arg(*filter.Currency))
+	}
+	if filter.PriceMin != nil {
+		conditions = append(conditions, "price >= "+arg(*filter.PriceMin))
+	}
+	if filter.PriceMax != nil {
+		conditions = append(conditions, "price <= "+arg(*filter.PriceMax))
+	}
+
+	column := order.Sort.column()
+	direction, comparison := "ASC", ">"
+	if order.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			column, comparison, arg(cursor.Value), arg(cursor.Id)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM flats WHERE %s ORDER BY %s %s, id %s LIMIT %s",
+		flatColumns, strings.Join(conditions, " AND "), column, direction, direction, arg(limit))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error fetching flats: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	flats := []models.Flat{}
+	for rows.Next() {
+		var flat models.Flat
+		if err := scanFlat(rows, &flat); err != nil {
+			log.Printf("Error scanning flat: %v\n", err)
+			return nil, err
+		}
+		flats = append(flats, flat)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return flats, nil
+}
+
+func (m *Memory) GetFlatsByHouseID(ctx context.Context, houseID int, filter FlatFilter, order FlatOrder, cursor *FlatCursor, limit int) ([]models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matching []models.Flat
+	for _, flat := range m.flats {
+		if int(flat.HouseId) == houseID && filter.matches(flat) {
+			matching = append(matching, flat)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return order.before(order.Sort.Value(matching[i]), matching[i].Id, matching[j])
+	})
+
+	flats := []models.Flat{}
+	for _, flat := range matching {
+		if len(flats) == limit {
+			break
+		}
+		if cursor != nil && !order.before(cursor.Value, cursor.Id, flat) {
+			continue
+		}
+		flats = append(flats, flat)
+	}
+	return flats, nil
+}
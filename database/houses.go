@@ -0,0 +1,190 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// HouseFilter narrows the house catalogue. Nil fields are not applied.
+type HouseFilter struct {
+	// Query is matched against the address and the developer; every word
+	// of it must occur in one of them.
+	Query            *string
+	YearMin          *int32
+	YearMax          *int32
+	HasApprovedFlats *bool
+	// IncludeArchived also returns archived houses, which only moderators
+	// may see.
+	IncludeArchived bool
+}
+
+// HouseCursor points just past the last house of the previous page.
+type HouseCursor struct {
+	UpdateAt time.Time
+	Id       int32
+}
+
+// SearchHouses returns up to limit houses that pass filter ordered by
+// update_at, most recently updated first when descending, starting after
+// cursor.
+func (p *Postgres) SearchHouses(ctx context.Context, filter HouseFilter, descending bool, cursor *HouseCursor, limit int) ([]models.House, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"TRUE"}
+	if !filter.IncludeArchived {
+		conditions = append(conditions, "archived_at IS NULL")
+	}
+	if filter.Query != nil {
+		conditions = append(conditions, "search @@ plainto_tsquery('simple', "+arg(*filter.Query)+")")
+	}
+	if filter.YearMin != nil {
+		conditions = append(conditions, "year >= "+arg(*filter.YearMin))
+	}
+	if filter.YearMax != nil {
+		conditions = append(conditions, "year <= "+arg(*filter.YearMax))
+	}
+	if filter.HasApprovedFlats != nil {
+		exists := "EXISTS (SELECT 1 FROM flats WHERE flats.house_id = houses.id AND flats.status = " + arg(models.APPROVED) + " AND flats.deleted_at IS NULL)"
+		if !*filter.HasApprovedFlats {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(update_at, id) %s (%s, %s)",
+			comparison, arg(cursor.UpdateAt), arg(cursor.Id)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM houses WHERE %s ORDER BY update_at %s, id %s LIMIT %s",
+		houseColumns, strings.Join(conditions, " AND "), direction, direction, arg(limit))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error searching houses: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	houses := []models.House{}
+	for rows.Next() {
+		var house models.House
+		if err := scanHouse(rows, &house); err != nil {
+			log.Printf("Error scanning house: %v\n", err)
+			return nil, err
+		}
+		houses = append(houses, house)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return houses, nil
+}
+
+func (m *Memory) SearchHouses(ctx context.Context, filter HouseFilter, descending bool, cursor *HouseCursor, limit int) ([]models.House, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := func(updateAt time.Time, id int32, b models.House) bool {
+		if descending {
+			return updateAt.After(b.UpdateAt) || updateAt.Equal(b.UpdateAt) && id > b.Id
+		}
+		return updateAt.Before(b.UpdateAt) || updateAt.Equal(b.UpdateAt) && id < b.Id
+	}
+
+	var matching []models.House
+	for _, house := range m.houses {
+		if m.houseMatches(house, filter) {
+			matching = append(matching, house)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return before(matching[i].UpdateAt, matching[i].Id, matching[j])
+	})
+
+	houses := []models.House{}
+	for _, house := range matching {
+		if len(houses) == limit {
+			break
+		}
+		if cursor != nil && !before(cursor.UpdateAt, cursor.Id, house) {
+			continue
+		}
+		houses = append(houses, house)
+	}
+	return houses, nil
+}
+
+func (m *Memory) houseMatches(house models.House, filter HouseFilter) bool {
+	if !filter.IncludeArchived && house.ArchivedAt != nil {
+		return false
+	}
+	if filter.YearMin != nil && house.Year < *filter.YearMin {
+		return false
+	}
+	if filter.YearMax != nil && house.Year > *filter.YearMax {
+		return false
+	}
+
+	if filter.Query != nil {
+		text := house.Address
+		if house.Developer != nil {
+			text += " " + *house.Developer
+		}
+
+		words := map[string]bool{}
+		for _, word := range searchWords(text) {
+			words[word] = true
+		}
+		for _, word := range searchWords(*filter.Query) {
+			if !words[word] {
+				return false
+			}
+		}
+	}
+
+	if filter.HasApprovedFlats != nil {
+		approved := false
+		for _, flat := range m.flats {
+			if flat.HouseId == house.Id && flat.Status == models.APPROVED {
+				approved = true
+				break
+			}
+		}
+		if approved != *filter.HasApprovedFlats {
+			return false
+		}
+	}
+
+	return true
+}
+
+// searchWords splits text into lower-cased words the way the 'simple' text
+// search configuration does.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
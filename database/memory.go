@@ -0,0 +1,777 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type flatKey struct {
+	houseId    int32
+	flatNumber int32
+}
+
+// Memory implements every repository of this package in process memory. It
+// enforces the same constraints as the SQL schema: unique emails, unique flat
+// numbers among the flats of a house that are not declined, and references
+// to existing users, houses and flats. A single mutex serializes all
+// operations, which also makes every method atomic. Nothing survives a
+// restart, so it is meant for tests and local development.
+type Memory struct {
+	mu sync.Mutex
+
+	users          map[int]models.User
+	userIdsByEmail map[string]int
+	lastUserId     int
+
+	houses      map[int32]models.House
+	lastHouseId int32
+
+	flats map[int32]models.Flat
+	// flatIdByKey holds the flat that owns each number. Declined flats do not
+	// own one, and a flat declined later stays here until its number is
+	// taken again.
+	flatIdByKey map[flatKey]int32
+	lastFlatId  int32
+	// deletedFlats holds soft-deleted flats, which no query returns.
+	deletedFlats map[int32]models.Flat
+
+	history       []models.FlatStatusChange
+	lastHistoryId int32
+
+	prices      []models.FlatPrice
+	lastPriceId int32
+
+	subscriptions      map[int32]models.Subscription
+	lastSubscriptionId int32
+
+	outbox             []models.Notification
+	lastNotificationId int32
+
+	refreshTokens      map[string]models.RefreshToken
+	lastRefreshTokenId int32
+	revokedTokens      map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:          map[int]models.User{},
+		userIdsByEmail: map[string]int{},
+		houses:         map[int32]models.House{},
+		flats:          map[int32]models.Flat{},
+		flatIdByKey:    map[flatKey]int32{},
+		deletedFlats:   map[int32]models.Flat{},
+		subscriptions:  map[int32]models.Subscription{},
+		refreshTokens:  map[string]models.RefreshToken{},
+		revokedTokens:  map[string]time.Time{},
+	}
+}
+
+var _ Store = (*Memory)(nil)
+
+func (m *Memory) userExists(id *int) bool {
+	if id == nil {
+		return true
+	}
+	_, ok := m.users[*id]
+	return ok
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.userIdsByEmail[user.Email]; ok {
+		return ErrUserExists
+	}
+
+	m.lastUserId++
+	user.ID = m.lastUserId
+	m.users[user.ID] = *user
+	m.userIdsByEmail[user.Email] = user.ID
+	return nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.userIdsByEmail[email]
+	if !ok {
+		return nil, nil
+	}
+
+	user := m.users[id]
+	return &user, nil
+}
+
+func (m *Memory) EnsureUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.userIdsByEmail[user.Email]; ok {
+		*user = m.users[id]
+		return nil
+	}
+
+	m.lastUserId++
+	user.ID = m.lastUserId
+	m.users[user.ID] = *user
+	m.userIdsByEmail[user.Email] = user.ID
+	return nil
+}
+
+func (m *Memory) CreateHouse(ctx context.Context, house *models.House) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(house.CreatedBy) {
+		return ErrUserNotFound
+	}
+
+	m.lastHouseId++
+	house.Id = m.lastHouseId
+	m.houses[house.Id] = *house
+	return nil
+}
+
+func (m *Memory) GetHouseByID(ctx context.Context, id int32) (*models.House, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	house, ok := m.houses[id]
+	if !ok {
+		return nil, nil
+	}
+	return &house, nil
+}
+
+func (m *Memory) CreateFlat(ctx context.Context, flat *models.Flat, reason *string) error {
+	if !canCreateWithStatus(flat.Status) {
+		return ErrInvalidStatusTransition
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	house, ok := m.houses[flat.HouseId]
+	if !ok {
+		return ErrHouseNotFound
+	}
+	key := flatKey{flat.HouseId, flat.FlatNumber}
+	if flat.Status != models.DECLINED && m.flatNumberTaken(key, 0) {
+		return ErrFlatExists
+	}
+	if !m.userExists(flat.CreatedBy) {
+		return ErrUserNotFound
+	}
+
+	flat.CreatedAt = time.Now()
+	house.UpdateAt = flat.CreatedAt
+	m.houses[house.Id] = house
+
+	m.lastFlatId++
+	flat.Id = m.lastFlatId
+	m.flats[flat.Id] = *flat
+	if flat.Status != models.DECLINED {
+		m.flatIdByKey[key] = flat.Id
+	}
+	m.recordPrice(models.FlatPrice{
+		FlatId:    flat.Id,
+		Price:     flat.Price,
+		Currency:  flat.Currency,
+		ChangedBy: flat.CreatedBy,
+		ChangedAt: flat.CreatedAt,
+	})
+
+	if flat.Status != models.CREATED {
+		m.recordStatusChange(models.FlatStatusChange{
+			FlatId:         flat.Id,
+			PreviousStatus: models.CREATED,
+			NewStatus:      flat.Status,
+			Reason:         reason,
+			ChangedAt:      flat.CreatedAt,
+		})
+	}
+
+	if flat.Status == models.APPROVED {
+		m.enqueueFlatApprovedNotifications(flat)
+	}
+
+	return nil
+}
+
+func (m *Memory) GetFlatByID(ctx context.Context, id int32) (*models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flat, ok := m.flats[id]
+	if !ok {
+		return nil, ErrFlatNotFound
+	}
+	return &flat, nil
+}
+
+func (m *Memory) UpdateFlatStatus(ctx context.Context, id int32, status string, moderatorID int, reason *string, lease time.Duration) (*models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateFlatStatus(id, status, moderatorID, reason, lease)
+}
+
+// updateFlatStatus follows the same rules as the Postgres implementation and
+// leaves the store untouched when it fails.
+func (m *Memory) updateFlatStatus(id int32, status string, moderatorID int, reason *string, lease time.Duration) (*models.Flat, error) {
+	flat, ok := m.flats[id]
+	if !ok {
+		return nil, ErrFlatNotFound
+	}
+	if !m.userExists(&moderatorID) {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now()
+	previousStatus := flat.Status
+
+	currentStatus := previousStatus
+	if currentStatus == models.ON_MODERATION && !claimLive(flat, now) {
+		currentStatus = models.CREATED
+	}
+
+	if currentStatus == models.ON_MODERATION && models.Status(status) == models.ON_MODERATION {
+		return nil, ErrFlatClaimed
+	}
+
+	if !models.CanTransition(models.ACTOR_MODERATOR, currentStatus, models.Status(status)) {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	moderator := moderatorID
+	if models.Status(status) == models.ON_MODERATION {
+		expiresAt := now.Add(lease)
+		flat.ClaimedBy = &moderator
+		flat.ClaimExpiresAt = &expiresAt
+	} else {
+		if flat.ClaimedBy == nil || *flat.ClaimedBy != moderatorID || !claimLive(flat, now) {
+			return nil, ErrFlatNotClaimed
+		}
+		flat.ClaimedBy = nil
+		flat.ClaimExpiresAt = nil
+	}
+	flat.Status = models.Status(status)
+	flat.ModeratedBy = &moderator
+	m.flats[id] = flat
+
+	m.recordStatusChange(models.FlatStatusChange{
+		FlatId:         flat.Id,
+		PreviousStatus: previousStatus,
+		NewStatus:      flat.Status,
+		ActorId:        &moderator,
+		Reason:         reason,
+		ChangedAt:      now,
+	})
+
+	if previousStatus != models.APPROVED && flat.Status == models.APPROVED {
+		m.enqueueFlatApprovedNotifications(&flat)
+	}
+
+	return &flat, nil
+}
+
+// flatNumberTaken reports whether a flat other than id owns key.
+func (m *Memory) flatNumberTaken(key flatKey, id int32) bool {
+	otherId, ok := m.flatIdByKey[key]
+	if !ok || otherId == id {
+		return false
+	}
+	other, ok := m.flats[otherId]
+	return ok && other.Status != models.DECLINED
+}
+
+// releaseFlatNumber frees the number of flat if flat owns it.
+func (m *Memory) releaseFlatNumber(flat models.Flat) {
+	key := flatKey{flat.HouseId, flat.FlatNumber}
+	if m.flatIdByKey[key] == flat.Id {
+		delete(m.flatIdByKey, key)
+	}
+}
+
+func claimLive(flat models.Flat, now time.Time) bool {
+	return flat.ClaimExpiresAt != nil && flat.ClaimExpiresAt.After(now)
+}
+
+func (m *Memory) UpdateFlatStatuses(ctx context.Context, updates []FlatStatusUpdate, moderatorID int, reason *string, lease time.Duration, atomic bool) ([]FlatStatusUpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Everything an update can touch is saved so that a failed atomic batch
+	// can be undone.
+	flats := map[int32]models.Flat{}
+	for _, update := range updates {
+		if flat, ok := m.flats[update.Id]; ok {
+			flats[update.Id] = flat
+		}
+	}
+	historyLen, lastHistoryId := len(m.history), m.lastHistoryId
+	outboxLen, lastNotificationId := len(m.outbox), m.lastNotificationId
+
+	// Updates are applied in id order, like the Postgres implementation
+	// does to avoid deadlocks.
+	order := make([]int, len(updates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return updates[order[a]].Id < updates[order[b]].Id
+	})
+
+	results := make([]FlatStatusUpdateResult, len(updates))
+	failed := false
+	for n, i := range order {
+		flat, err := m.updateFlatStatus(updates[i].Id, string(updates[i].Status), moderatorID, reason, lease)
+		if err != nil && !isFlatError(err) {
+			if atomic {
+				return nil, err
+			}
+			results[i] = FlatStatusUpdateResult{Err: err}
+			for _, j := range order[n+1:] {
+				results[j] = FlatStatusUpdateResult{Err: ErrNotAttempted}
+			}
+			break
+		}
+		results[i] = FlatStatusUpdateResult{Flat: flat, Err: err}
+		failed = failed || err != nil
+	}
+
+	if atomic && failed {
+		for id, flat := range flats {
+			m.flats[id] = flat
+		}
+		m.history, m.lastHistoryId = m.history[:historyLen], lastHistoryId
+		m.outbox, m.lastNotificationId = m.outbox[:outboxLen], lastNotificationId
+	}
+
+	return results, nil
+}
+
+func (m *Memory) ReleaseExpiredClaims(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	reason := "moderation lease expired"
+
+	var ids []int32
+	for id, flat := range m.flats {
+		if flat.Status == models.ON_MODERATION && !claimLive(flat, now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		flat := m.flats[id]
+		flat.Status = models.CREATED
+		flat.ClaimedBy = nil
+		flat.ClaimExpiresAt = nil
+		m.flats[id] = flat
+
+		m.recordStatusChange(models.FlatStatusChange{
+			FlatId:         id,
+			PreviousStatus: models.ON_MODERATION,
+			NewStatus:      models.CREATED,
+			Reason:         &reason,
+			ChangedAt:      now,
+		})
+	}
+
+	return int64(len(ids)), nil
+}
+
+func (m *Memory) recordStatusChange(change models.FlatStatusChange) {
+	m.lastHistoryId++
+	change.Id = m.lastHistoryId
+	m.history = append(m.history, change)
+}
+
+func (m *Memory) GetFlatStatusHistory(ctx context.Context, flatID int32) ([]models.FlatStatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := []models.FlatStatusChange{}
+	for _, change := range m.history {
+		if change.FlatId == flatID {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+// pending reports whether the flat waits for a moderator and matches filter.
+func (m *Memory) pending(flat models.Flat, filter QueueFilter, now time.Time) bool {
+	if flat.Status != models.CREATED && !(flat.Status == models.ON_MODERATION && !claimLive(flat, now)) {
+		return false
+	}
+	if filter.HouseId != nil && flat.HouseId != *filter.HouseId {
+		return false
+	}
+	if filter.Developer != nil {
+		developer := m.houses[flat.HouseId].Developer
+		if developer == nil || strings.ToLower(*developer) != strings.ToLower(*filter.Developer) {
+			return false
+		}
+	}
+	if filter.Currency != nil && flat.Currency != *filter.Currency {
+		return false
+	}
+	if filter.PriceMin != nil && flat.Price < *filter.PriceMin {
+		return false
+	}
+	if filter.PriceMax != nil && flat.Price > *filter.PriceMax {
+		return false
+	}
+	return true
+}
+
+// queue returns the pending flats matching filter, oldest first.
+func (m *Memory) queue(filter QueueFilter, now time.Time) []models.Flat {
+	var flats []models.Flat
+	for _, flat := range m.flats {
+		if m.pending(flat, filter, now) {
+			flats = append(flats, flat)
+		}
+	}
+
+	sort.Slice(flats, func(i, j int) bool {
+		return queueLess(flats[i].CreatedAt, flats[i].Id, flats[j].CreatedAt, flats[j].Id)
+	})
+	return flats
+}
+
+func queueLess(createdAtA time.Time, idA int32, createdAtB time.Time, idB int32) bool {
+	if !createdAtA.Equal(createdAtB) {
+		return createdAtA.Before(createdAtB)
+	}
+	return idA < idB
+}
+
+func (m *Memory) GetModerationQueue(ctx context.Context, filter QueueFilter, cursor *QueueCursor, limit int) ([]models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flats := []models.Flat{}
+	for _, flat := range m.queue(filter, time.Now()) {
+		if len(flats) == limit {
+			break
+		}
+		if cursor != nil && !queueLess(cursor.CreatedAt, cursor.Id, flat.CreatedAt, flat.Id) {
+			continue
+		}
+		flats = append(flats, flat)
+	}
+	return flats, nil
+}
+
+func (m *Memory) AssignFromQueue(ctx context.Context, filter QueueFilter, count int, moderatorID int, lease time.Duration) ([]models.Flat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(&moderatorID) {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(lease)
+
+	flats := []models.Flat{}
+	for _, flat := range m.queue(filter, now) {
+		if len(flats) == count {
+			break
+		}
+
+		previousStatus := flat.Status
+		moderator := moderatorID
+		flat.Status = models.ON_MODERATION
+		flat.ModeratedBy = &moderator
+		flat.ClaimedBy = &moderator
+		flat.ClaimExpiresAt = &expiresAt
+		m.flats[flat.Id] = flat
+		flats = append(flats, flat)
+
+		m.recordStatusChange(models.FlatStatusChange{
+			FlatId:         flat.Id,
+			PreviousStatus: previousStatus,
+			NewStatus:      flat.Status,
+			ActorId:        &moderator,
+			ChangedAt:      now,
+		})
+	}
+
+	return flats, nil
+}
+
+func (m *Memory) CreateSubscription(ctx context.Context, subscription *models.Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.houses[subscription.HouseId]; !ok {
+		return ErrHouseNotFound
+	}
+
+	for _, existing := range m.subscriptions {
+		if existing.HouseId == subscription.HouseId && existing.Email == subscription.Email {
+			subscription.Id = existing.Id
+			subscription.CreatedAt = existing.CreatedAt
+			return nil
+		}
+	}
+
+	m.lastSubscriptionId++
+	subscription.Id = m.lastSubscriptionId
+	subscription.CreatedAt = time.Now()
+	m.subscriptions[subscription.Id] = *subscription
+	return nil
+}
+
+func (m *Memory) GetSubscriptionByID(ctx context.Context, id int32) (*models.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscription, ok := m.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &subscription, nil
+}
+
+func (m *Memory) GetSubscriptionsByEmail(ctx context.Context, email string) ([]models.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscriptions := []models.Subscription{}
+	for _, subscription := range m.subscriptions {
+		if subscription.Email == email {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Id < subscriptions[j].Id })
+	return subscriptions, nil
+}
+
+func (m *Memory) DeleteSubscription(ctx context.Context, id int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	outbox := m.outbox[:0]
+	for _, notification := range m.outbox {
+		if notification.Status == models.NOTIFICATION_PENDING && notification.Kind == models.FLAT_APPROVED {
+			var payload models.FlatApprovedPayload
+			if json.Unmarshal(notification.Payload, &payload) == nil && payload.SubscriptionId == id {
+				continue
+			}
+		}
+		outbox = append(outbox, notification)
+	}
+	m.outbox = outbox
+
+	delete(m.subscriptions, id)
+	return nil
+}
+
+func (m *Memory) enqueueFlatApprovedNotifications(flat *models.Flat) {
+	var subscriptions []models.Subscription
+	for _, subscription := range m.subscriptions {
+		if subscription.HouseId == flat.HouseId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Id < subscriptions[j].Id })
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		payload, _ := json.Marshal(models.FlatApprovedPayload{
+			SubscriptionId: subscription.Id,
+			HouseId:        flat.HouseId,
+			FlatId:         flat.Id,
+			FlatNumber:     flat.FlatNumber,
+			Price:          flat.Price,
+			Currency:       flat.Currency,
+			Rooms:          flat.Rooms,
+		})
+
+		m.lastNotificationId++
+		m.outbox = append(m.outbox, models.Notification{
+			Id:            m.lastNotificationId,
+			Kind:          models.FLAT_APPROVED,
+			Recipient:     subscription.Email,
+			Payload:       payload,
+			Status:        models.NOTIFICATION_PENDING,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+}
+
+func (m *Memory) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]models.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var due []int
+	for i, notification := range m.outbox {
+		if notification.Status == models.NOTIFICATION_PENDING && !notification.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return m.outbox[due[a]].NextAttemptAt.Before(m.outbox[due[b]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	notifications := []models.Notification{}
+	for _, i := range due {
+		m.outbox[i].NextAttemptAt = now.Add(lease)
+		notifications = append(notifications, m.outbox[i])
+	}
+	return notifications, nil
+}
+
+func (m *Memory) notification(id int32) *models.Notification {
+	for i := range m.outbox {
+		if m.outbox[i].Id == id {
+			return &m.outbox[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) MarkNotificationSent(ctx context.Context, id int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if notification := m.notification(id); notification != nil {
+		now := time.Now()
+		notification.Status = models.NOTIFICATION_SENT
+		notification.Attempts++
+		notification.LastError = nil
+		notification.SentAt = &now
+	}
+	return nil
+}
+
+func (m *Memory) MarkNotificationFailed(ctx context.Context, id int32, lastError string, nextAttemptAt time.Time, dead bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if notification := m.notification(id); notification != nil {
+		notification.Status = models.NOTIFICATION_PENDING
+		if dead {
+			notification.Status = models.NOTIFICATION_DEAD
+		}
+		notification.Attempts++
+		notification.LastError = &lastError
+		notification.NextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertRefreshToken(token, time.Now())
+}
+
+func (m *Memory) insertRefreshToken(token *models.RefreshToken, now time.Time) error {
+	if _, ok := m.refreshTokens[token.TokenHash]; ok {
+		return errors.New("refresh token hash already exists")
+	}
+	if !m.userExists(&token.UserId) {
+		return ErrUserNotFound
+	}
+
+	m.lastRefreshTokenId++
+	token.Id = m.lastRefreshTokenId
+	token.CreatedAt = now
+	m.refreshTokens[token.TokenHash] = *token
+	return nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.refreshTokens[hash]
+	if !ok || current.RevokedAt != nil {
+		return ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+
+	if current.UsedAt != nil {
+		m.revokeRefreshTokenFamily(current.FamilyId, now)
+		return ErrRefreshTokenReused
+	}
+
+	if now.After(current.ExpiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+
+	next.FamilyId = current.FamilyId
+	next.UserId = current.UserId
+	next.Email = current.Email
+	next.UserType = current.UserType
+	if err := m.insertRefreshToken(next, now); err != nil {
+		return err
+	}
+
+	current.UsedAt = &now
+	m.refreshTokens[hash] = current
+	return nil
+}
+
+func (m *Memory) revokeRefreshTokenFamily(familyId string, now time.Time) {
+	for hash, token := range m.refreshTokens {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			m.refreshTokens[hash] = token
+		}
+	}
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, jti string, expiresAt time.Time, refreshHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for revoked, revokedUntil := range m.revokedTokens {
+		if revokedUntil.Before(now) {
+			delete(m.revokedTokens, revoked)
+		}
+	}
+	if _, ok := m.revokedTokens[jti]; !ok {
+		m.revokedTokens[jti] = expiresAt
+	}
+
+	if token, ok := m.refreshTokens[refreshHash]; ok && refreshHash != "" {
+		m.revokeRefreshTokenFamily(token.FamilyId, now)
+	}
+	return nil
+}
+
+func (m *Memory) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, revoked := m.revokedTokens[jti]
+	return revoked, nil
+}
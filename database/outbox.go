@@ -0,0 +1,142 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// enqueueFlatApprovedNotifications writes one outbox row per subscriber of the
+// flat's house. It runs inside the transaction that approves the flat, so the
+// notifications exist if and only if the status change was committed.
+func enqueueFlatApprovedNotifications(ctx context.Context, tx *sql.Tx, flat *models.Flat) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, email FROM subscriptions WHERE house_id = $1 ORDER BY id", flat.HouseId)
+	if err != nil {
+		return err
+	}
+
+	var subscriptions []models.Subscription
+	for rows.Next() {
+		var subscription models.Subscription
+		if err := rows.Scan(&subscription.Id, &subscription.Email); err != nil {
+			rows.Close()
+			return err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `INSERT INTO notification_outbox (kind, recipient, payload, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`
+
+	for _, subscription := range subscriptions {
+		payload, err := json.Marshal(models.FlatApprovedPayload{
+			SubscriptionId: subscription.Id,
+			HouseId:        flat.HouseId,
+			FlatId:         flat.Id,
+			FlatNumber:     flat.FlatNumber,
+			Price:          flat.Price,
+			Currency:       flat.Currency,
+			Rooms:          flat.Rooms,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query, models.FLAT_APPROVED, subscription.Email, payload, models.NOTIFICATION_PENDING, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimNotifications picks up to limit pending notifications that are due and
+// pushes their next attempt into the future by lease. Rows locked by another
+// replica are skipped, so concurrent workers never claim the same row; if the
+// claiming worker dies, the row becomes due again once the lease expires.
+func (p *Postgres) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]models.Notification, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	query := `UPDATE notification_outbox SET next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, recipient, payload, status, attempts, next_attempt_at, created_at`
+
+	rows, err := p.db.QueryContext(ctx, query, now.Add(lease), models.NOTIFICATION_PENDING, now, limit)
+	if err != nil {
+		log.Printf("Error claiming notifications: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var notification models.Notification
+		err := rows.Scan(&notification.Id, &notification.Kind, &notification.Recipient, &notification.Payload,
+			&notification.Status, &notification.Attempts, &notification.NextAttemptAt, &notification.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning notification: %v\n", err)
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (p *Postgres) MarkNotificationSent(ctx context.Context, id int32) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE notification_outbox SET status = $1, attempts = attempts + 1, last_error = NULL, sent_at = $2 WHERE id = $3"
+	_, err := p.db.ExecContext(ctx, query, models.NOTIFICATION_SENT, time.Now(), id)
+	if err != nil {
+		log.Printf("Error marking notification as sent: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// MarkNotificationFailed records a failed delivery attempt. The notification is
+// retried at nextAttemptAt, or dead-lettered when dead is set.
+func (p *Postgres) MarkNotificationFailed(ctx context.Context, id int32, lastError string, nextAttemptAt time.Time, dead bool) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	status := models.NOTIFICATION_PENDING
+	if dead {
+		status = models.NOTIFICATION_DEAD
+	}
+
+	query := "UPDATE notification_outbox SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $4"
+	_, err := p.db.ExecContext(ctx, query, status, lastError, nextAttemptAt, id)
+	if err != nil {
+		log.Printf("Error marking notification as failed: %v\n", err)
+		return err
+	}
+
+	return nil
+}
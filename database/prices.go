@@ -0,0 +1,153 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func insertFlatPrice(ctx context.Context, tx *sql.Tx, price *models.FlatPrice) error {
+	query := "INSERT INTO flat_prices (flat_id, price, currency, changed_by, changed_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	err := tx.QueryRowContext(ctx, query, price.FlatId, price.Price, price.Currency, price.ChangedBy, price.ChangedAt).Scan(&price.Id)
+	if known := constraintError(err); known != nil {
+		return known
+	}
+	return err
+}
+
+// GetFlatPrices returns the flat's price history, oldest first. The first
+// entry is the price the flat was created with.
+func (p *Postgres) GetFlatPrices(ctx context.Context, flatID int32) ([]models.FlatPrice, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, flat_id, price, currency, changed_by, changed_at FROM flat_prices WHERE flat_id = $1 ORDER BY changed_at, id"
+	rows, err := p.db.QueryContext(ctx, query, flatID)
+	if err != nil {
+		log.Printf("Error fetching flat prices: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.FlatPrice{}
+	for rows.Next() {
+		var price models.FlatPrice
+		if err := rows.Scan(&price.Id, &price.FlatId, &price.Price, &price.Currency, &price.ChangedBy, &price.ChangedAt); err != nil {
+			log.Printf("Error scanning flat price: %v\n", err)
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// GetPeakPrices returns, for each of the flats, the highest price it has had
+// since the given time, counting the price it already had then. Only prices
+// in the flat's current currency are compared. Flats without a price history
+// are left out.
+func (p *Postgres) GetPeakPrices(ctx context.Context, flatIDs []int32, since time.Time) (map[int32]int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT prices.flat_id, MAX(prices.price) FROM (
+			SELECT flat_id, price, currency FROM flat_prices WHERE flat_id = ANY($1) AND changed_at >= $2
+			UNION ALL
+			(SELECT DISTINCT ON (flat_id) flat_id, price, currency FROM flat_prices
+				WHERE flat_id = ANY($1) AND changed_at < $2 ORDER BY flat_id, changed_at DESC, id DESC)
+		) prices
+		JOIN flats ON flats.id = prices.flat_id AND flats.currency = prices.currency
+		GROUP BY prices.flat_id`
+	rows, err := p.db.QueryContext(ctx, query, pq.Int32Array(flatIDs), since)
+	if err != nil {
+		log.Printf("Error fetching peak prices: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	peaks := map[int32]int64{}
+	for rows.Next() {
+		var flatID int32
+		var price int64
+		if err := rows.Scan(&flatID, &price); err != nil {
+			log.Printf("Error scanning peak price: %v\n", err)
+			return nil, err
+		}
+		peaks[flatID] = price
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return peaks, nil
+}
+
+func (m *Memory) recordPrice(price models.FlatPrice) {
+	m.lastPriceId++
+	price.Id = m.lastPriceId
+	m.prices = append(m.prices, price)
+}
+
+func (m *Memory) GetFlatPrices(ctx context.Context, flatID int32) ([]models.FlatPrice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prices := []models.FlatPrice{}
+	for _, price := range m.prices {
+		if price.FlatId == flatID {
+			prices = append(prices, price)
+		}
+	}
+	return prices, nil
+}
+
+func (m *Memory) GetPeakPrices(ctx context.Context, flatIDs []int32, since time.Time) (map[int32]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := map[int32]bool{}
+	for _, id := range flatIDs {
+		wanted[id] = true
+	}
+
+	// Prices are recorded in order, so the last one before since is the one
+	// the flat had at that time.
+	var candidates []models.FlatPrice
+	before := map[int32]models.FlatPrice{}
+	for _, price := range m.prices {
+		if !wanted[price.FlatId] {
+			continue
+		}
+		if price.ChangedAt.Before(since) {
+			before[price.FlatId] = price
+		} else {
+			candidates = append(candidates, price)
+		}
+	}
+	for _, price := range before {
+		candidates = append(candidates, price)
+	}
+
+	peaks := map[int32]int64{}
+	for _, price := range candidates {
+		flat, ok := m.flats[price.FlatId]
+		if !ok || flat.Currency != price.Currency {
+			continue
+		}
+		if peak, ok := peaks[price.FlatId]; !ok || price.Price > peak {
+			peaks[price.FlatId] = price.Price
+		}
+	}
+	return peaks, nil
+}
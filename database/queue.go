@@ -0,0 +1,211 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QueueFilter narrows the moderation queue. Nil fields are not applied.
+type QueueFilter struct {
+	HouseId   *int32
+	Developer *string
+	Currency  *string
+	PriceMin  *int64
+	PriceMax  *int64
+}
+
+// QueueCursor points just past the last flat of the previous page.
+type QueueCursor struct {
+	CreatedAt time.Time
+	Id        int32
+}
+
+// queueConditions returns the WHERE clause selecting flats that wait for a
+// moderator: new flats and flats whose moderation claim has expired.
+func queueConditions(filter QueueFilter, now time.Time, args []interface{}) (string, []interface{}) {
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{
+		fmt.Sprintf("(status = %s OR (status = %s AND COALESCE(claim_expires_at <= %s, TRUE)))",
+			arg(models.CREATED), arg(models.ON_MODERATION), arg(now)),
+		"deleted_at IS NULL",
+	}
+
+	if filter.HouseId != nil {
+		conditions = append(conditions, "house_id = "+arg(*filter.HouseId))
+	}
+	if filter.Developer != nil {
+		conditions = append(conditions, "house_id IN (SELECT id FROM houses WHERE lower(developer) = lower("+arg(*filter.Developer)+"))")
+	}
+	if filter.Currency != nil {
+		conditions = append(conditions, "currency = "+arg(*filter.Currency))
+	}
+	if filter.PriceMin != nil {
+		conditions = append(conditions, "price >= "+arg(*filter.PriceMin))
+	}
+	if filter.PriceMax != nil {
+		conditions = append(conditions, "price <= "+arg(*filter.PriceMax))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetModerationQueue returns up to limit pending flats across all houses,
+// oldest first, starting after cursor.
+func (p *Postgres) GetModerationQueue(ctx context.Context, filter QueueFilter, cursor *QueueCursor, limit int) ([]models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	where, args := queueConditions(filter, time.Now(), nil)
+	if cursor != nil {
+		args = append(args, cursor.CreatedAt, cursor.Id)
+		where += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf("SELECT %s FROM flats WHERE %s ORDER BY created_at, id LIMIT $%d", flatColumns, where, len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error fetching moderation queue: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	flats := []models.Flat{}
+	for rows.Next() {
+		var flat models.Flat
+		if err := scanFlat(rows, &flat); err != nil {
+			log.Printf("Error scanning flat: %v\n", err)
+			return nil, err
+		}
+		flats = append(flats, flat)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	return flats, nil
+}
+
+// AssignFromQueue claims up to count of the oldest pending flats for the
+// moderator. Rows already locked by a concurrent assignment are skipped, so
+// moderators assigning in parallel never receive the same flat.
+func (p *Postgres) AssignFromQueue(ctx context.Context, filter QueueFilter, count int, moderatorID int, lease time.Duration) ([]models.Flat, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var flats []models.Flat
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		flats, err = assignFromQueue(ctx, tx, filter, count, moderatorID, lease)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return flats, nil
+}
+
+func assignFromQueue(ctx context.Context, tx *sql.Tx, filter QueueFilter, count int, moderatorID int, lease time.Duration) ([]models.Flat, error) {
+	now := time.Now()
+	where, args := queueConditions(filter, now, nil)
+	args = append(args, count)
+	query := fmt.Sprintf("SELECT id, status FROM flats WHERE %s ORDER BY created_at, id LIMIT $%d FOR UPDATE SKIP LOCKED",
+		where, len(args))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error selecting flats to assign: %v\n", err)
+		return nil, err
+	}
+
+	var ids []int32
+	previousStatuses := map[int32]models.Status{}
+	for rows.Next() {
+		var id int32
+		var status models.Status
+		if err := rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			log.Printf("Error scanning flat: %v\n", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+		previousStatuses[id] = status
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	flats := []models.Flat{}
+	if len(ids) == 0 {
+		return flats, nil
+	}
+
+	query = `UPDATE flats SET status = $1, moderated_by = $2, claimed_by = $2, claim_expires_at = $3
+		WHERE id = ANY($4) RETURNING ` + flatColumns
+	rows, err = tx.QueryContext(ctx, query, models.ON_MODERATION, moderatorID, now.Add(lease), pq.Int32Array(ids))
+	if err != nil {
+		if known := constraintError(err); known != nil {
+			return nil, known
+		}
+		log.Printf("Error assigning flats: %v\n", err)
+		return nil, err
+	}
+
+	for rows.Next() {
+		var flat models.Flat
+		if err := scanFlat(rows, &flat); err != nil {
+			rows.Close()
+			log.Printf("Error scanning flat: %v\n", err)
+			return nil, err
+		}
+		flats = append(flats, flat)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error with rows: %v\n", err)
+		return nil, err
+	}
+
+	sort.Slice(flats, func(i, j int) bool {
+		if !flats[i].CreatedAt.Equal(flats[j].CreatedAt) {
+			return flats[i].CreatedAt.Before(flats[j].CreatedAt)
+		}
+		return flats[i].Id < flats[j].Id
+	})
+
+	for _, flat := range flats {
+		err := insertFlatStatusChange(ctx, tx, &models.FlatStatusChange{
+			FlatId:         flat.Id,
+			PreviousStatus: previousStatuses[flat.Id],
+			NewStatus:      flat.Status,
+			ActorId:        &moderatorID,
+			ChangedAt:      now,
+		})
+		if err != nil {
+			log.Printf("Error recording flat status change: %v\n", err)
+			return nil, err
+		}
+	}
+
+	return flats, nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"time"
+)
+
+// UserRepository stores accounts. Emails are unique.
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	// GetUserByEmail returns nil without an error for unknown emails.
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// EnsureUser creates user unless the email is taken and fills user with
+	// the stored account either way.
+	EnsureUser(ctx context.Context, user *models.User) error
+}
+
+type HouseRepository interface {
+	CreateHouse(ctx context.Context, house *models.House) error
+	// GetHouseByID returns nil without an error for unknown houses.
+	GetHouseByID(ctx context.Context, id int32) (*models.House, error)
+	SearchHouses(ctx context.Context, filter HouseFilter, descending bool, cursor *HouseCursor, limit int) ([]models.House, error)
+	EditHouse(ctx context.Context, id int32, edit HouseEdit) (*models.House, error)
+	ArchiveHouse(ctx context.Context, id int32) (*models.House, error)
+}
+
+// FlatRepository stores flats together with their moderation state and
+// status history.
+type FlatRepository interface {
+	CreateFlat(ctx context.Context, flat *models.Flat, reason *string) error
+	// GetFlatByID returns ErrFlatNotFound for unknown and deleted flats.
+	GetFlatByID(ctx context.Context, id int32) (*models.Flat, error)
+	EditFlat(ctx context.Context, id int32, edit FlatEdit, actorID int) (*models.Flat, error)
+	DeleteFlat(ctx context.Context, id int32) error
+	// GetFlatsByHouseID returns up to limit of the house's flats that pass
+	// filter, ordered by order and starting after cursor.
+	GetFlatsByHouseID(ctx context.Context, houseID int, filter FlatFilter, order FlatOrder, cursor *FlatCursor, limit int) ([]models.Flat, error)
+	UpdateFlatStatus(ctx context.Context, id int32, status string, moderatorID int, reason *string, lease time.Duration) (*models.Flat, error)
+	UpdateFlatStatuses(ctx context.Context, updates []FlatStatusUpdate, moderatorID int, reason *string, lease time.Duration, atomic bool) ([]FlatStatusUpdateResult, error)
+	ReleaseExpiredClaims(ctx context.Context) (int64, error)
+	GetFlatStatusHistory(ctx context.Context, flatID int32) ([]models.FlatStatusChange, error)
+	GetFlatPrices(ctx context.Context, flatID int32) ([]models.FlatPrice, error)
+	GetPeakPrices(ctx context.Context, flatIDs []int32, since time.Time) (map[int32]int64, error)
+	GetModerationQueue(ctx context.Context, filter QueueFilter, cursor *QueueCursor, limit int) ([]models.Flat, error)
+	AssignFromQueue(ctx context.Context, filter QueueFilter, count int, moderatorID int, lease time.Duration) ([]models.Flat, error)
+}
+
+type SubscriptionRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.Subscription) error
+	// GetSubscriptionByID returns nil without an error for unknown ids.
+	GetSubscriptionByID(ctx context.Context, id int32) (*models.Subscription, error)
+	GetSubscriptionsByEmail(ctx context.Context, email string) ([]models.Subscription, error)
+	DeleteSubscription(ctx context.Context, id int32) error
+}
+
+// TokenRepository stores refresh tokens and the access token denylist.
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) error
+	RevokeSession(ctx context.Context, jti string, expiresAt time.Time, refreshHash string) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// NotificationRepository is the consumer side of the notification outbox.
+type NotificationRepository interface {
+	ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]models.Notification, error)
+	MarkNotificationSent(ctx context.Context, id int32) error
+	MarkNotificationFailed(ctx context.Context, id int32, lastError string, nextAttemptAt time.Time, dead bool) error
+}
+
+// Store is everything the service keeps, as implemented by Postgres.
+type Store interface {
+	UserRepository
+	HouseRepository
+	FlatRepository
+	SubscriptionRepository
+	TokenRepository
+	NotificationRepository
+}
+
+var _ Store = (*Postgres)(nil)
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"avito-backend-bootcamp/models"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+func (p *Postgres) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	token.CreatedAt = time.Now()
+	query := `INSERT INTO refresh_tokens (token_hash, family_id, user_id, email, user_type, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := p.db.QueryRowContext(ctx, query, token.TokenHash, token.FamilyId, token.UserId, token.Email, token.UserType, token.ExpiresAt, token.CreatedAt).Scan(&token.Id)
+	if err != nil {
+		if known := constraintError(err); known != nil {
+			return known
+		}
+		log.Printf("Error creating refresh token: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// RotateRefreshToken marks the token identified by hash as used and stores
+// next in its family, inheriting the owner. Presenting a token that was
+// already used means it leaked, so the whole family is revoked and
+// ErrRefreshTokenReused is returned.
+func (p *Postgres) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	reused := false
+	err := p.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		reused, err = rotateRefreshToken(ctx, tx, hash, next)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// rotateRefreshToken reports reuse through its boolean result rather than an
+// error so that the family revocation is committed.
+func rotateRefreshToken(ctx context.Context, tx *sql.Tx, hash string, next *models.RefreshToken) (bool, error) {
+	var current models.RefreshToken
+	var userId *int
+	query := `SELECT id, family_id, user_id, email, user_type, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, hash).Scan(&current.Id, &current.FamilyId, &userId, &current.Email, &current.UserType,
+		&current.ExpiresAt, &current.UsedAt, &current.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrRefreshTokenInvalid
+		}
+		log.Printf("Error fetching refresh token: %v\n", err)
+		return false, err
+	}
+
+	now := time.Now()
+
+	if current.RevokedAt != nil {
+		return false, ErrRefreshTokenInvalid
+	}
+
+	if current.UsedAt != nil {
+		if err := revokeRefreshTokenFamily(ctx, tx, current.FamilyId, now); err != nil {
+			log.Printf("Error revoking refresh token family: %v\n", err)
+			return false, err
+		}
+		return true, nil
+	}
+
+	// Tokens issued before users were recorded cannot be rotated; their owners
+	// have to log in again.
+	if now.After(current.ExpiresAt) || userId == nil {
+		return false, ErrRefreshTokenInvalid
+	}
+	current.UserId = *userId
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = $1 WHERE id = $2", now, current.Id); err != nil {
+		log.Printf("Error marking refresh token as used: %v\n", err)
+		return false, err
+	}
+
+	next.FamilyId = current.FamilyId
+	next.UserId = current.UserId
+	next.Email = current.Email
+	next.UserType = current.UserType
+	next.CreatedAt = now
+
+	query = `INSERT INTO refresh_tokens (token_hash, family_id, user_id, email, user_type, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = tx.QueryRowContext(ctx, query, next.TokenHash, next.FamilyId, next.UserId, next.Email, next.UserType, next.ExpiresAt, next.CreatedAt).Scan(&next.Id)
+	if err != nil {
+		if known := constraintError(err); known != nil {
+			return false, known
+		}
+		log.Printf("Error creating refresh token: %v\n", err)
+		return false, err
+	}
+
+	return false, nil
+}
+
+// RevokeSession ends a login: the access token's jti is added to the
+// denylist until the token would have expired anyway, and when refreshHash
+// is not empty every refresh token descending from the same login is revoked
+// too. Unknown refresh tokens are ignored.
+func (p *Postgres) RevokeSession(ctx context.Context, jti string, expiresAt time.Time, refreshHash string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := revokeToken(ctx, tx, jti, expiresAt); err != nil {
+			return err
+		}
+
+		if refreshHash == "" {
+			return nil
+		}
+
+		query := `UPDATE refresh_tokens SET revoked_at = $1
+			WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $2)`
+		if _, err := tx.ExecContext(ctx, query, time.Now(), refreshHash); err != nil {
+			log.Printf("Error revoking refresh token family: %v\n", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func revokeRefreshTokenFamily(ctx context.Context, tx *sql.Tx, familyId string, now time.Time) error {
+	_, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL", now, familyId)
+	return err
+}
+
+// revokeToken prunes denylist entries past their expiry on the way.
+func revokeToken(ctx context.Context, tx *sql.Tx, jti string, expiresAt time.Time) error {
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < $1", now); err != nil {
+		log.Printf("Error pruning revoked tokens: %v\n", err)
+		return err
+	}
+
+	query := "INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING"
+	if _, err := tx.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		log.Printf("Error revoking token: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *Postgres) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var revoked bool
+	err := p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)", jti).Scan(&revoked)
+	if err != nil {
+		log.Printf("Error checking revoked token: %v\n", err)
+		return false, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrHouseNotFound = errors.New("house not found")
+	ErrFlatExists    = errors.New("flat with this number already exists in the house")
+)
+
+// WithTx runs fn inside a transaction. The transaction is committed when fn
+// returns nil and rolled back when it returns an error or panics; fn's error
+// is returned unchanged so callers can still compare it with the errors of
+// this package.
+func (p *Postgres) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v\n", err)
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v\n", err)
+	}
+	return err
+}
+
+// Postgres error codes mapped to errors of this package.
+const (
+	uniqueViolation = "23505"
+	queryCanceled   = "57014"
+)
+
+func pqErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+// constraintErrors maps the schema's constraints to the errors their
+// violations are reported as, the same ones Memory returns.
+var constraintErrors = map[string]error{
+	"users_email_key":                   ErrUserExists,
+	"flats_house_flat_number_key":       ErrFlatExists,
+	"flats_house_id_fkey":               ErrHouseNotFound,
+	"subscriptions_house_id_fkey":       ErrHouseNotFound,
+	"flat_status_history_flat_id_fkey":  ErrFlatNotFound,
+	"flat_prices_flat_id_fkey":          ErrFlatNotFound,
+	"houses_created_by_fkey":            ErrUserNotFound,
+	"flats_created_by_fkey":             ErrUserNotFound,
+	"flats_moderated_by_fkey":           ErrUserNotFound,
+	"flats_claimed_by_fkey":             ErrUserNotFound,
+	"flat_status_history_actor_id_fkey": ErrUserNotFound,
+	"flat_prices_changed_by_fkey":       ErrUserNotFound,
+	"refresh_tokens_user_id_fkey":       ErrUserNotFound,
+}
+
+// constraintError returns the error reported for the constraint err
+// violates, or nil when err is not a violation of a known constraint.
+func constraintError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return constraintErrors[pqErr.Constraint]
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package env reads settings from environment variables. Unset or malformed
+// values fall back to the given default.
+package env
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Int reads a non-negative integer.
+func Int(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+
+	return value
+}
+
+// Duration reads a positive duration in Go syntax, e.g. "30s" or "5m".
+func Duration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+// OptionalDuration is Duration for settings where zero turns a limit off.
+func OptionalDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+
+	return value
+}
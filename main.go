@@ -1,22 +1,113 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/auth"
 	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/moderation"
+	"avito-backend-bootcamp/notifications"
 	"avito-backend-bootcamp/routers"
 )
 
 func main() {
-	err := database.InitDB()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	if err := moderation.LoadRules(); err != nil {
+		log.Fatalf("Failed to load moderation rules: %v", err)
+	}
 
+	store, err := openStore()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	routes := routers.ApiHandleFunctions{}
+	sender, err := notifications.NewSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize notification sender: %v", err)
+	}
+
+	worker := notifications.NewWorker(store, sender)
+	go worker.Run(context.Background())
+	go releaseExpiredClaims(context.Background(), store, time.Minute)
+
+	routes := routers.ApiHandleFunctions{
+		AuthOnlyAPI: api.AuthOnlyAPI{
+			Houses:        store,
+			Flats:         store,
+			Subscriptions: store,
+			Tokens:        store,
+		},
+		ModerationsOnlyAPI: api.ModerationsOnlyAPI{
+			Houses: store,
+			Flats:  store,
+		},
+		NoAuthAPI: api.NoAuthAPI{
+			Users:         store,
+			Subscriptions: store,
+			Tokens:        store,
+		},
+		Revocations: store,
+	}
 	log.Printf("Server started")
 
 	router := routers.NewRouter(routes)
 	log.Fatal(router.Run(":8080"))
 }
+
+// openStore returns the storage selected by STORAGE: "postgres", the default,
+// or "memory", which keeps everything in process and loses it on restart.
+func openStore() (database.Store, error) {
+	switch storage := os.Getenv("STORAGE"); storage {
+	case "", "postgres":
+		config := database.ConfigFromEnv()
+		db, err := database.Open(config)
+		if err != nil {
+			return nil, err
+		}
+		return database.NewPostgres(db, config), nil
+	case "memory":
+		log.Printf("Using in-memory storage, data will be lost on restart")
+		return database.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q", storage)
+	}
+}
+
+// releaseExpiredClaims periodically hands flats whose moderation lease has
+// expired back to the queue. Running it on several replicas is harmless.
+func releaseExpiredClaims(ctx context.Context, flats database.FlatRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		released, err := flats.ReleaseExpiredClaims(ctx)
+		if err != nil {
+			log.Printf("Error releasing expired moderation claims: %v", err)
+			continue
+		}
+		if released > 0 {
+			log.Printf("Released %d expired moderation claims", released)
+		}
+	}
+}
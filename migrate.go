@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/migrations"
+)
+
+const migrateUsage = "usage: main migrate up | down | status | to <version>"
+
+// runMigrate implements the "migrate" subcommand against the database named
+// by DB_NAME.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.Connect(database.ConfigFromEnv())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrations.Up(db)
+	case args[0] == "down" && len(args) == 1:
+		return migrations.Down(db)
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		return migrations.To(db, version)
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
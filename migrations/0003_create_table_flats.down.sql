@@ -0,0 +1 @@
+DROP TABLE IF EXISTS flats;
\ No newline at end of file
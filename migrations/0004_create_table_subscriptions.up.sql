@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id SERIAL PRIMARY KEY,
+    house_id INT NOT NULL,
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (house_id) REFERENCES houses (id),
+    UNIQUE (house_id, email)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS notification_outbox;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS user_id;
+ALTER TABLE flats DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE flats DROP COLUMN IF EXISTS created_by;
+ALTER TABLE houses DROP COLUMN IF EXISTS created_by;
\ No newline at end of file
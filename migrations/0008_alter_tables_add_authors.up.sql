@@ -0,0 +1,4 @@
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS created_by INT REFERENCES users (id);
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS created_by INT REFERENCES users (id);
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS moderated_by INT REFERENCES users (id);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users (id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE flats DROP COLUMN IF EXISTS claim_expires_at;
+ALTER TABLE flats DROP COLUMN IF EXISTS claimed_by;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS claimed_by INT REFERENCES users (id);
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS claim_expires_at TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS flat_status_history (
+    id SERIAL PRIMARY KEY,
+    flat_id INT NOT NULL,
+    previous_status TEXT NOT NULL,
+    new_status TEXT NOT NULL,
+    actor_id INT,
+    reason TEXT,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (flat_id) REFERENCES flats (id),
+    FOREIGN KEY (actor_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS flat_status_history_flat_id_idx ON flat_status_history (flat_id, changed_at);
\ No newline at end of file
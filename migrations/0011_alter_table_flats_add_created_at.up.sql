@@ -0,0 +1,4 @@
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS flats_moderation_queue_idx ON flats (created_at, id)
+    WHERE status IN ('created', 'on moderation');
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS flats_house_rooms_idx;
+DROP INDEX IF EXISTS flats_house_price_idx;
+DROP INDEX IF EXISTS flats_house_flat_number_idx;
\ No newline at end of file
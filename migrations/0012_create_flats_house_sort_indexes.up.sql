@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS flats_house_flat_number_idx ON flats (house_id, flat_number, id);
+CREATE INDEX IF NOT EXISTS flats_house_price_idx ON flats (house_id, price, id);
+CREATE INDEX IF NOT EXISTS flats_house_rooms_idx ON flats (house_id, rooms, id);
\ No newline at end of file
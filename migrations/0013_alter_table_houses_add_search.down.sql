@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS houses_update_at_idx;
+DROP INDEX IF EXISTS houses_search_idx;
+ALTER TABLE houses DROP COLUMN IF EXISTS search;
\ No newline at end of file
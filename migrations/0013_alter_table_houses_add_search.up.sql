@@ -0,0 +1,5 @@
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS search tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', address || ' ' || COALESCE(developer, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS houses_search_idx ON houses USING GIN (search);
+CREATE INDEX IF NOT EXISTS houses_update_at_idx ON houses (update_at, id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS flats_house_flat_number_key;
+ALTER TABLE flats ADD CONSTRAINT flats_house_id_flat_number_key UNIQUE (house_id, flat_number);
+ALTER TABLE flats DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
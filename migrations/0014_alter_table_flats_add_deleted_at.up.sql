@@ -0,0 +1,5 @@
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Withdrawn flats free their number for a new listing.
+ALTER TABLE flats DROP CONSTRAINT IF EXISTS flats_house_id_flat_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS flats_house_flat_number_key ON flats (house_id, flat_number) WHERE deleted_at IS NULL;
\ No newline at end of file
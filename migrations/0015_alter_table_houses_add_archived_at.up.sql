@@ -0,0 +1 @@
+ALTER TABLE houses ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS flat_prices;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS flat_prices (
+    id SERIAL PRIMARY KEY,
+    flat_id INT NOT NULL,
+    price INT NOT NULL,
+    changed_by INT,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (flat_id) REFERENCES flats (id),
+    FOREIGN KEY (changed_by) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS flat_prices_flat_id_idx ON flat_prices (flat_id, changed_at);
+
+-- Existing flats start their history with the price they have now.
+INSERT INTO flat_prices (flat_id, price, changed_by, changed_at)
+SELECT id, price, created_by, created_at FROM flats;
\ No newline at end of file
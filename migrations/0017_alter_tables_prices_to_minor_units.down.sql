@@ -0,0 +1,10 @@
+UPDATE notification_outbox
+SET payload = (payload - 'currency') || jsonb_build_object('price', (payload->>'price')::BIGINT / 100)
+WHERE kind = 'flat_approved' AND status = 'pending';
+
+ALTER TABLE flat_prices DROP COLUMN IF EXISTS currency;
+ALTER TABLE flat_prices ALTER COLUMN price TYPE INT USING price / 100;
+
+ALTER TABLE flats DROP CONSTRAINT IF EXISTS flats_price_positive;
+ALTER TABLE flats DROP COLUMN IF EXISTS currency;
+ALTER TABLE flats ALTER COLUMN price TYPE INT USING price / 100;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- Prices were whole roubles; they become kopecks with an explicit currency.
+ALTER TABLE flats ALTER COLUMN price TYPE BIGINT USING price::BIGINT * 100;
+ALTER TABLE flats ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+-- Existing rows are not checked so that old listings with a bad price do not
+-- block the migration; every new or edited row is.
+ALTER TABLE flats ADD CONSTRAINT flats_price_positive CHECK (price > 0) NOT VALID;
+
+ALTER TABLE flat_prices ALTER COLUMN price TYPE BIGINT USING price::BIGINT * 100;
+ALTER TABLE flat_prices ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+UPDATE notification_outbox
+SET payload = payload || jsonb_build_object('price', (payload->>'price')::BIGINT * 100, 'currency', 'RUB')
+WHERE kind = 'flat_approved' AND status = 'pending';
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS flats_house_flat_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS flats_house_flat_number_key ON flats (house_id, flat_number) WHERE deleted_at IS NULL;
\ No newline at end of file
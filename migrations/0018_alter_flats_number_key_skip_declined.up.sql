@@ -0,0 +1,3 @@
+-- Declined flats keep their number, so a duplicate can be recorded as declined.
+DROP INDEX IF EXISTS flats_house_flat_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS flats_house_flat_number_key ON flats (house_id, flat_number) WHERE deleted_at IS NULL AND status <> 'declined';
\ No newline at end of file
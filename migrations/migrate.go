@@ -1,31 +1,276 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
 	"log"
-	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 )
 
-func Migrate(db *sql.DB) {
-	migrationFIles := []string{
-		"create_table_users.sql",
-		"create_table_houses.sql",
-		"create_table_flats.sql",
+// Migrations are pairs of files named "<version>_<name>.up.sql" and
+// "<version>_<name>.down.sql". Versions are applied in ascending order and
+// recorded in the schema_migrations table. A released migration must never
+// be edited; add a new one instead.
+//
+//go:embed *.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// lockKey identifies the Postgres advisory lock held while migrating, so that
+// replicas starting at the same time apply every migration exactly once.
+const lockKey int64 = 4_716_802_133
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
 	}
 
-	for _, file := range migrationFIles {
-		path := filepath.Join("migrations", file)
-		sqlCode, err := os.ReadFile(path)
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
 
+		content, err := files.ReadFile(entry.Name())
 		if err != nil {
-			log.Fatalf("Error while reading migration file %s: %v", path, err)
+			return nil, err
 		}
 
-		_, err = db.Exec(string(sqlCode))
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
 
-		if err != nil {
-			log.Fatalf("Error while executing migration %s: %v", path, err)
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up script", migration)
 		}
+		migrations = append(migrations, *migration)
 	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Migrate brings the schema up to date. It is what the service runs on boot.
+func Migrate(db *sql.DB) error {
+	return Up(db)
+}
+
+// Up applies every pending migration.
+func Up(db *sql.DB) error {
+	return withLock(db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		if len(migrations) == 0 {
+			return nil
+		}
+		return migrate(conn, migrations, applied, migrations[len(migrations)-1].Version)
+	})
+}
+
+// Down reverts the most recently applied migration.
+func Down(db *sql.DB) error {
+	return withLock(db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version]; ok {
+				return revert(conn, migrations[i])
+			}
+		}
+		return nil
+	})
+}
+
+// To applies or reverts migrations until exactly the ones up to version are
+// applied. Version 0 reverts everything.
+func To(db *sql.DB, version int) error {
+	return withLock(db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		known := version == 0
+		for _, migration := range migrations {
+			known = known || migration.Version == version
+		}
+		if !known {
+			return fmt.Errorf("unknown migration version %d", version)
+		}
+		return migrate(conn, migrations, applied, version)
+	})
+}
+
+// Status lists every known migration and when it was applied.
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := withLock(db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, migration := range migrations {
+			status := MigrationStatus{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func migrate(conn *sql.Conn, migrations []Migration, applied map[int]time.Time, version int) error {
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := apply(conn, migration); err != nil {
+			return err
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok || migrations[i].Version <= version {
+			continue
+		}
+		if err := revert(conn, migrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func apply(conn *sql.Conn, migration Migration) error {
+	err := inTx(conn, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, time.Now())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %s: %w", migration, err)
+	}
+
+	log.Printf("Applied migration %s", migration)
+	return nil
+}
+
+func revert(conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("migration %s cannot be reverted: it has no down script", migration)
+	}
+
+	err := inTx(conn, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("revert migration %s: %w", migration, err)
+	}
+
+	log.Printf("Reverted migration %s", migration)
+	return nil
+}
+
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, with the list of migrations and the versions already applied.
+func withLock(db *sql.DB, fn func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = appliedAt
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
 }
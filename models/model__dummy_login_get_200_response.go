@@ -1,5 +1,6 @@
 package models
 
 type DummyLoginGet200Response struct {
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
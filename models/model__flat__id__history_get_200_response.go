@@ -0,0 +1,5 @@
+package models
+
+type FlatIdHistoryGet200Response struct {
+	History []FlatStatusChange `json:"history"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// FlatIdPatchRequest lists the attributes to change; omitted ones are kept.
+type FlatIdPatchRequest struct {
+	FlatNumber *int32  `json:"flat_number,omitempty" binding:"omitempty,gt=0"`
+	Price      *int64  `json:"price,omitempty" binding:"omitempty,gt=0"`
+	Currency   *string `json:"currency,omitempty" binding:"omitempty,currency"`
+	Rooms      *int32  `json:"rooms,omitempty" binding:"omitempty,gt=0"`
+}
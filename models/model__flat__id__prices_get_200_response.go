@@ -0,0 +1,5 @@
+package models
+
+type FlatIdPricesGet200Response struct {
+	Prices []FlatPrice `json:"prices"`
+}
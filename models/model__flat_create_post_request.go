@@ -1,8 +1,9 @@
 package models
 
 type FlatCreatePostRequest struct {
-	HouseId    int32 `json:"house_id"`
-	FlatNumber int32 `json:"flat_number"`
-	Price      int32 `json:"price"`
-	Rooms      int32 `json:"rooms,omitempty"`
+	HouseId    int32  `json:"house_id" binding:"required,gt=0"`
+	FlatNumber int32  `json:"flat_number" binding:"required,gt=0"`
+	Price      int64  `json:"price" binding:"required,gt=0"`
+	Currency   string `json:"currency,omitempty" binding:"omitempty,currency"`
+	Rooms      int32  `json:"rooms,omitempty" binding:"required,gt=0"`
 }
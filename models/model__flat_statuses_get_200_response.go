@@ -0,0 +1,5 @@
+package models
+
+type FlatStatusesGet200Response struct {
+	Transitions []StatusTransition `json:"transitions"`
+}
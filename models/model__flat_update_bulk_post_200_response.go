@@ -0,0 +1,13 @@
+package models
+
+type FlatUpdateBulkResult struct {
+	Id      int32          `json:"id"`
+	Success bool           `json:"success"`
+	Flat    *Flat          `json:"flat,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+type FlatUpdateBulkPost200Response struct {
+	Mode    BulkMode               `json:"mode"`
+	Results []FlatUpdateBulkResult `json:"results"`
+}
@@ -0,0 +1,19 @@
+package models
+
+type BulkMode string
+
+const (
+	BULK_ATOMIC      BulkMode = "atomic"
+	BULK_BEST_EFFORT BulkMode = "best_effort"
+)
+
+type FlatUpdateBulkItem struct {
+	Id     int32  `json:"id" binding:"required,gt=0"`
+	Status Status `json:"status" binding:"required,flat_status"`
+}
+
+type FlatUpdateBulkPostRequest struct {
+	Items  []FlatUpdateBulkItem `json:"items" binding:"required,min=1,max=500,dive"`
+	Reason *string              `json:"reason,omitempty"`
+	Mode   BulkMode             `json:"mode,omitempty" binding:"omitempty,oneof=atomic best_effort"`
+}
@@ -1,6 +1,7 @@
 package models
 
 type FlatUpdatePostRequest struct {
-	Id     int32  `json:"id"`
-	Status Status `json:"status,omitempty"`
+	Id     int32   `json:"id" binding:"required,gt=0"`
+	Status Status  `json:"status,omitempty" binding:"required,flat_status"`
+	Reason *string `json:"reason,omitempty"`
 }
@@ -1,5 +1,6 @@
 package models
 
 type HouseIdGet200Response struct {
-	Flats []Flat `json:"flats"`
+	Flats      []Flat `json:"flats"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,9 @@
+package models
+
+// HouseIdPatchRequest lists the attributes to change; omitted ones are kept.
+// An empty developer clears it.
+type HouseIdPatchRequest struct {
+	Address   *string `json:"address,omitempty" binding:"omitempty,notblank"`
+	Year      *int32  `json:"year,omitempty" binding:"omitempty,min=1700,house_year"`
+	Developer *string `json:"developer,omitempty"`
+}
@@ -1,5 +1,6 @@
 package models
 
 type HouseIdSubscribePostRequest struct {
-	Email string `json:"email"`
+	// Email is optional and must be the caller's own address.
+	Email string `json:"email,omitempty"`
 }
@@ -1,7 +1,7 @@
 package models
 
 type HouseCreatePostRequest struct {
-	Address   string  `json:"address"`
-	Year      int32   `json:"year"`
+	Address   string  `json:"address" binding:"required,notblank"`
+	Year      int32   `json:"year" binding:"required,min=1700,house_year"`
 	Developer *string `json:"developer,omitempty"`
 }
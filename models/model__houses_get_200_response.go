@@ -0,0 +1,6 @@
+package models
+
+type HousesGet200Response struct {
+	Houses     []House `json:"houses"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
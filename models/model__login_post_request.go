@@ -1,6 +1,6 @@
 package models
 
 type LoginPostRequest struct {
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty" binding:"required,email"`
+	Password string `json:"password,omitempty" binding:"required"`
 }
@@ -0,0 +1,5 @@
+package models
+
+type LogoutPostRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
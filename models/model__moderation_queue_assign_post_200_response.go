@@ -0,0 +1,5 @@
+package models
+
+type ModerationQueueAssignPost200Response struct {
+	Flats []Flat `json:"flats"`
+}
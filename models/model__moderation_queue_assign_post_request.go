@@ -0,0 +1,11 @@
+package models
+
+type ModerationQueueAssignPostRequest struct {
+	Count     int32   `json:"count,omitempty"`
+	HouseId   *int32  `json:"house_id,omitempty"`
+	Developer *string `json:"developer,omitempty"`
+	// Currency is required with price bounds.
+	Currency *string `json:"currency,omitempty" binding:"omitempty,currency"`
+	PriceMin *int64  `json:"price_min,omitempty"`
+	PriceMax *int64  `json:"price_max,omitempty"`
+}
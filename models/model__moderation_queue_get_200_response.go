@@ -0,0 +1,6 @@
+package models
+
+type ModerationQueueGet200Response struct {
+	Flats      []Flat `json:"flats"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
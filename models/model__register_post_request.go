@@ -1,7 +1,7 @@
 package models
 
 type RegisterPostRequest struct {
-	Email    string   `json:"email,omitempty"`
-	Password string   `json:"password,omitempty"`
-	UserType UserType `json:"user_type,omitempty"`
+	Email    string   `json:"email,omitempty" binding:"required,email"`
+	Password string   `json:"password,omitempty" binding:"required,password"`
+	UserType UserType `json:"user_type,omitempty" binding:"required,oneof=client moderator"`
 }
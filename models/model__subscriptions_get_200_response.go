@@ -0,0 +1,5 @@
+package models
+
+type SubscriptionsGet200Response struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+}
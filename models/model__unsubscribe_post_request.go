@@ -0,0 +1,8 @@
+package models
+
+// UnsubscribePostRequest is submitted by the unsubscribe confirmation page
+// as a form, or by mail clients as a one-click unsubscribe with the token in
+// the query string.
+type UnsubscribePostRequest struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/currency"
+)
+
+// Prices are integers in the currency's minor units, e.g. kopecks for RUB,
+// so that they never go through floating point.
+
+// DefaultCurrency is used for flats created without a currency.
+const DefaultCurrency = "RUB"
+
+// ParseCurrency returns the canonical ISO 4217 code for code, which is
+// matched case-insensitively. It reports false for unknown codes.
+func ParseCurrency(code string) (string, bool) {
+	unit, err := currency.ParseISO(strings.TrimSpace(code))
+	if err != nil {
+		return "", false
+	}
+	return unit.String(), true
+}
+
+// FormatPrice renders a price in minor units in major units of its currency,
+// e.g. "12500.50 RUB".
+func FormatPrice(price int64, code string) string {
+	scale := 2
+	if unit, err := currency.ParseISO(code); err == nil {
+		scale, _ = currency.Standard.Rounding(unit)
+	}
+	if scale == 0 {
+		return fmt.Sprintf("%d %s", price, code)
+	}
+
+	sign := ""
+	if price < 0 {
+		sign, price = "-", -price
+	}
+
+	divisor := int64(1)
+	for i := 0; i < scale; i++ {
+		divisor *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, price/divisor, scale, price%divisor, code)
+}
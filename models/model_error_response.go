@@ -0,0 +1,53 @@
+package models
+
+// ErrorResponse is returned for errors that clients are expected to handle
+// programmatically. Code is stable; Error is meant for humans. Requests that
+// fail validation get ERROR_VALIDATION_FAILED with every offending field
+// listed in Fields.
+type ErrorResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes one invalid field of a request: a body field by its
+// JSON name, with the index for elements of a list, e.g. "items[0].id", or a
+// query or path parameter by its name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	ERROR_INVALID_STATUS_TRANSITION = "invalid_status_transition"
+	ERROR_FLAT_NOT_FOUND            = "flat_not_found"
+	ERROR_FLAT_CLAIMED              = "flat_claimed"
+	ERROR_FLAT_NOT_CLAIMED          = "flat_not_claimed"
+	ERROR_ROLLED_BACK               = "rolled_back"
+	ERROR_NOT_ATTEMPTED             = "not_attempted"
+	ERROR_HOUSE_NOT_FOUND           = "house_not_found"
+	ERROR_FLAT_EXISTS               = "flat_exists"
+	ERROR_SUBSCRIPTION_NOT_FOUND    = "subscription_not_found"
+	ERROR_USER_EXISTS               = "user_exists"
+	ERROR_INVALID_CREDENTIALS       = "invalid_credentials"
+	ERROR_INVALID_TOKEN             = "invalid_token"
+	ERROR_UNAUTHORIZED              = "unauthorized"
+	ERROR_FORBIDDEN                 = "forbidden"
+	ERROR_MALFORMED_REQUEST         = "malformed_request"
+	ERROR_VALIDATION_FAILED         = "validation_failed"
+	ERROR_TIMEOUT                   = "timeout"
+	ERROR_INTERNAL                  = "internal_error"
+)
+
+// Codes of FieldError.
+const (
+	FIELD_REQUIRED         = "required"
+	FIELD_TOO_SMALL        = "too_small"
+	FIELD_TOO_LARGE        = "too_large"
+	FIELD_INVALID_TYPE     = "invalid_type"
+	FIELD_INVALID_VALUE    = "invalid_value"
+	FIELD_INVALID_EMAIL    = "invalid_email"
+	FIELD_WEAK_PASSWORD    = "weak_password"
+	FIELD_INVALID_CURRENCY = "invalid_currency"
+)
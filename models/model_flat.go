@@ -1,10 +1,27 @@
 package models
 
+import (
+	"time"
+)
+
 type Flat struct {
-	Id         int32  `json:"id"`
-	HouseId    int32  `json:"house_id"`
-	FlatNumber int32  `json:"flat_number"`
-	Price      int32  `json:"price"`
-	Rooms      int32  `json:"rooms"`
-	Status     Status `json:"status"`
+	Id             int32      `json:"id"`
+	HouseId        int32      `json:"house_id"`
+	FlatNumber     int32      `json:"flat_number"`
+	Price          int64      `json:"price"`
+	Currency       string     `json:"currency"`
+	Rooms          int32      `json:"rooms"`
+	Status         Status     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CreatedBy      *int       `json:"created_by,omitempty"`
+	ModeratedBy    *int       `json:"moderated_by,omitempty"`
+	ClaimedBy      *int       `json:"claimed_by,omitempty"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+
+	// PriceDropped and PriceDropPercent are filled in by HouseIdGet for
+	// flats whose price fell below the highest one they had within the
+	// price drop window. They are not stored.
+	PriceDropped     bool     `json:"price_dropped,omitempty"`
+	PriceDropPercent *float64 `json:"price_drop_percent,omitempty"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// FlatPrice is one entry of a flat's price history: the price it was listed
+// at from ChangedAt on. ChangedBy is the user who set it.
+type FlatPrice struct {
+	Id        int32     `json:"id"`
+	FlatId    int32     `json:"flat_id"`
+	Price     int64     `json:"price"`
+	Currency  string    `json:"currency"`
+	ChangedBy *int      `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
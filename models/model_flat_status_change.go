@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// FlatStatusChange is one entry of a flat's moderation audit log. ActorId is
+// empty for changes made by the service itself, such as releasing an expired
+// moderation claim.
+type FlatStatusChange struct {
+	Id             int32     `json:"id"`
+	FlatId         int32     `json:"flat_id"`
+	PreviousStatus Status    `json:"previous_status"`
+	NewStatus      Status    `json:"new_status"`
+	ActorId        *int      `json:"actor_id,omitempty"`
+	Reason         *string   `json:"reason,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
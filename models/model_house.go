@@ -11,4 +11,7 @@ type House struct {
 	Developer *string   `json:"developer,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdateAt  time.Time `json:"update_at,omitempty"`
+	CreatedBy *int      `json:"created_by,omitempty"`
+	// ArchivedAt is set for houses hidden from clients.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
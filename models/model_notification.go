@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type NotificationKind string
+
+const (
+	FLAT_APPROVED NotificationKind = "flat_approved"
+)
+
+type NotificationStatus string
+
+const (
+	NOTIFICATION_PENDING NotificationStatus = "pending"
+	NOTIFICATION_SENT    NotificationStatus = "sent"
+	NOTIFICATION_DEAD    NotificationStatus = "dead"
+)
+
+type Notification struct {
+	Id            int32              `json:"id"`
+	Kind          NotificationKind   `json:"kind"`
+	Recipient     string             `json:"recipient"`
+	Payload       json.RawMessage    `json:"payload"`
+	Status        NotificationStatus `json:"status"`
+	Attempts      int32              `json:"attempts"`
+	LastError     *string            `json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at"`
+	CreatedAt     time.Time          `json:"created_at"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+}
+
+type FlatApprovedPayload struct {
+	SubscriptionId int32  `json:"subscription_id"`
+	HouseId        int32  `json:"house_id"`
+	FlatId         int32  `json:"flat_id"`
+	FlatNumber     int32  `json:"flat_number"`
+	Price          int64  `json:"price"`
+	Currency       string `json:"currency"`
+	Rooms          int32  `json:"rooms"`
+}
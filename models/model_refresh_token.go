@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type RefreshToken struct {
+	Id        int32
+	TokenHash string
+	FamilyId  string
+	UserId    int
+	Email     string
+	UserType  string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
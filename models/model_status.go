@@ -8,3 +8,77 @@ const (
 	DECLINED      Status = "declined"
 	ON_MODERATION Status = "on moderation"
 )
+
+// Actor is who moves a flat from one status to another.
+type Actor string
+
+const (
+	// ACTOR_MODERATOR is a moderator going through the queue.
+	ACTOR_MODERATOR Actor = "moderator"
+	// ACTOR_RULES is auto-moderation deciding on a flat as it is created.
+	ACTOR_RULES Actor = "rules"
+	// ACTOR_AUTHOR is the flat's author editing it, which sends it back to
+	// moderation.
+	ACTOR_AUTHOR Actor = "author"
+)
+
+type StatusTransition struct {
+	From Status `json:"from"`
+	// To lists the statuses a moderator may move the flat to.
+	To []Status `json:"to"`
+	// Auto lists the statuses auto-moderation may put a new flat in.
+	Auto []Status `json:"auto,omitempty"`
+	// Edit lists the statuses an author's edit may send the flat back to.
+	Edit []Status `json:"edit,omitempty"`
+}
+
+// StatusTransitions is the moderation state machine: every status a flat can
+// be in, together with the statuses each actor may move it to. Approved and
+// declined flats are final for moderators; only their author's edits reopen
+// them for moderation.
+var StatusTransitions = []StatusTransition{
+	{From: CREATED, To: []Status{ON_MODERATION}, Auto: []Status{APPROVED, DECLINED}},
+	{From: ON_MODERATION, To: []Status{APPROVED, DECLINED, CREATED}, Edit: []Status{CREATED}},
+	{From: APPROVED, To: []Status{}, Edit: []Status{CREATED}},
+	{From: DECLINED, To: []Status{}, Edit: []Status{CREATED}},
+}
+
+func (s Status) IsValid() bool {
+	for _, transition := range StatusTransitions {
+		if transition.From == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t StatusTransition) targets(actor Actor) []Status {
+	switch actor {
+	case ACTOR_MODERATOR:
+		return t.To
+	case ACTOR_RULES:
+		return t.Auto
+	case ACTOR_AUTHOR:
+		return t.Edit
+	default:
+		return nil
+	}
+}
+
+// CanTransition reports whether actor may move a flat from one status to
+// the other.
+func CanTransition(actor Actor, from, to Status) bool {
+	for _, transition := range StatusTransitions {
+		if transition.From != from {
+			continue
+		}
+		for _, status := range transition.targets(actor) {
+			if status == to {
+				return true
+			}
+		}
+	}
+
+	return false
+}
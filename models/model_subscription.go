@@ -0,0 +1,12 @@
+package models
+
+import (
+	"time"
+)
+
+type Subscription struct {
+	Id        int32     `json:"id"`
+	HouseId   int32     `json:"house_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
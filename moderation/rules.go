@@ -0,0 +1,220 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"avito-backend-bootcamp/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Field names a numeric flat attribute a rule can check.
+type Field string
+
+const (
+	FieldPrice      Field = "price"
+	FieldRooms      Field = "rooms"
+	FieldFlatNumber Field = "flat_number"
+)
+
+// Rule declines a flat whose field lies outside [Min, Max]. Either bound may
+// be omitted. Price bounds are in minor units of Currency, which price rules
+// must name. A flat_number rule with Duplicate set has no bounds and declines
+// a flat whose number another flat of the house already has.
+type Rule struct {
+	Name      string `json:"name" yaml:"name"`
+	Field     Field  `json:"field" yaml:"field"`
+	Min       *int64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max       *int64 `json:"max,omitempty" yaml:"max,omitempty"`
+	Currency  string `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty" yaml:"duplicate,omitempty"`
+	Reason    string `json:"reason" yaml:"reason"`
+}
+
+// TrustedDeveloper lists the accounts that publish flats on behalf of a
+// developer. Only their flats in the developer's houses skip moderation;
+// anybody else posting into those houses is moderated as usual.
+type TrustedDeveloper struct {
+	Name     string   `json:"name" yaml:"name"`
+	Accounts []string `json:"accounts" yaml:"accounts"`
+}
+
+// Rules is the content of the rules file.
+type Rules struct {
+	Decline           []Rule             `json:"decline" yaml:"decline"`
+	TrustedDevelopers []TrustedDeveloper `json:"trusted_developers" yaml:"trusted_developers"`
+}
+
+// Decision is the outcome of running the rules against a new flat. Status is
+// models.CREATED when no rule applies and the flat is left for a moderator.
+type Decision struct {
+	Status models.Status
+	Reason *string
+}
+
+var rules = &Rules{}
+
+// LoadRules reads the rules file named by MODERATION_RULES_FILE. Files ending
+// in ".json" are parsed as JSON, everything else as YAML. Without the
+// variable no rules are active and every flat goes to a moderator.
+func LoadRules() error {
+	path := os.Getenv("MODERATION_RULES_FILE")
+	if path == "" {
+		rules = &Rules{}
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read moderation rules: %w", err)
+	}
+
+	loaded := &Rules{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, loaded)
+	} else {
+		err = yaml.Unmarshal(data, loaded)
+	}
+	if err != nil {
+		return fmt.Errorf("parse moderation rules %s: %w", path, err)
+	}
+
+	if err := loaded.validate(); err != nil {
+		return fmt.Errorf("moderation rules %s: %w", path, err)
+	}
+
+	rules = loaded
+	return nil
+}
+
+func (r *Rules) validate() error {
+	for i, rule := range r.Decline {
+		switch rule.Field {
+		case FieldPrice:
+			currency, ok := models.ParseCurrency(rule.Currency)
+			if !ok {
+				return fmt.Errorf("rule %d: price rules need a valid currency, got %q", i, rule.Currency)
+			}
+			r.Decline[i].Currency = currency
+		case FieldRooms, FieldFlatNumber:
+			if rule.Currency != "" {
+				return fmt.Errorf("rule %d: currency only applies to price rules", i)
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown field %q", i, rule.Field)
+		}
+		if rule.Duplicate {
+			if rule.Field != FieldFlatNumber {
+				return fmt.Errorf("rule %d: duplicate only applies to flat_number rules", i)
+			}
+			if rule.Min != nil || rule.Max != nil {
+				return fmt.Errorf("rule %d: duplicate rules take no min or max", i)
+			}
+		} else if rule.Min == nil && rule.Max == nil {
+			return fmt.Errorf("rule %d: min or max is required", i)
+		}
+		if rule.Reason == "" {
+			return fmt.Errorf("rule %d: reason is required", i)
+		}
+	}
+	for i, developer := range r.TrustedDevelopers {
+		if strings.TrimSpace(developer.Name) == "" {
+			return fmt.Errorf("trusted developer %d: name is required", i)
+		}
+		if len(developer.Accounts) == 0 {
+			return fmt.Errorf("trusted developer %s: accounts are required", developer.Name)
+		}
+	}
+	return nil
+}
+
+// Evaluate decides what happens to a flat right after it is created by the
+// account with the given email. Decline rules are checked first, in file
+// order, and the first match wins; only a flat that passes all of them is
+// approved, and only when its author is one of the accounts trusted for the
+// house's developer. house may be nil when it is unknown. Duplicate rules are
+// left to EvaluateDuplicate.
+func Evaluate(flat *models.Flat, house *models.House, author string) Decision {
+	for _, rule := range rules.Decline {
+		if rule.matches(flat) {
+			return rule.decline()
+		}
+	}
+
+	if house != nil && house.Developer != nil {
+		for _, developer := range rules.TrustedDevelopers {
+			if developer.trusts(*house.Developer, author) {
+				reason := fmt.Sprintf("trusted developer %s", *house.Developer)
+				return Decision{Status: models.APPROVED, Reason: &reason}
+			}
+		}
+	}
+
+	return Decision{Status: models.CREATED}
+}
+
+// EvaluateDuplicate decides what happens to a flat whose number is already
+// taken in its house. It declines the flat by the first duplicate rule, and
+// reports false when there is none and the flat must not be created.
+func EvaluateDuplicate() (Decision, bool) {
+	for _, rule := range rules.Decline {
+		if rule.Duplicate {
+			return rule.decline(), true
+		}
+	}
+	return Decision{}, false
+}
+
+// trusts reports whether author publishes flats for developer name.
+func (d TrustedDeveloper) trusts(name, author string) bool {
+	if !strings.EqualFold(strings.TrimSpace(d.Name), strings.TrimSpace(name)) {
+		return false
+	}
+	for _, account := range d.Accounts {
+		if strings.EqualFold(strings.TrimSpace(account), strings.TrimSpace(author)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule Rule) decline() Decision {
+	reason := rule.Reason
+	if rule.Name != "" {
+		reason = fmt.Sprintf("%s (rule %s)", rule.Reason, rule.Name)
+	}
+	return Decision{Status: models.DECLINED, Reason: &reason}
+}
+
+func (rule Rule) matches(flat *models.Flat) bool {
+	if rule.Duplicate {
+		return false
+	}
+
+	var value int64
+	switch rule.Field {
+	case FieldPrice:
+		if flat.Currency != rule.Currency {
+			return false
+		}
+		value = flat.Price
+	case FieldRooms:
+		value = int64(flat.Rooms)
+	case FieldFlatNumber:
+		value = int64(flat.FlatNumber)
+	default:
+		return false
+	}
+
+	if rule.Min != nil && value < *rule.Min {
+		return true
+	}
+	if rule.Max != nil && value > *rule.Max {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// LogSender is a stand-in for a real mail server. It writes every message to
+// Writer, or to the standard logger when Writer is nil.
+type LogSender struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func NewFileSender(path string) (*LogSender, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening notification log file: %v", err)
+	}
+
+	return &LogSender{Writer: file}, nil
+}
+
+func (s *LogSender) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.Writer == nil {
+		log.Printf("Notification to %s: %s\n%s", message.To, message.Subject, message.Body)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.Writer, "To: %s\nSubject: %s\n\n%s\n\n", message.To, message.Subject, message.Body)
+	return err
+}
@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"avito-backend-bootcamp/auth"
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// unsubscribeURL builds the link included in every subscription email. It
+// points at PUBLIC_BASE_URL, which defaults to the local development server.
+func unsubscribeURL(subscriptionId int32, email string) (string, error) {
+	token, err := auth.GenerateUnsubscribeToken(subscriptionId, email)
+	if err != nil {
+		return "", err
+	}
+
+	baseURL := os.Getenv("PUBLIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return baseURL + "/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+// Render turns an outbox row into the message that is handed to a Sender.
+func Render(notification models.Notification) (Message, error) {
+	switch notification.Kind {
+	case models.FLAT_APPROVED:
+		var payload models.FlatApprovedPayload
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return Message{}, fmt.Errorf("invalid %s payload: %v", notification.Kind, err)
+		}
+
+		link, err := unsubscribeURL(payload.SubscriptionId, notification.Recipient)
+		if err != nil {
+			return Message{}, err
+		}
+
+		currency := payload.Currency
+		if currency == "" {
+			currency = models.DefaultCurrency
+		}
+
+		return Message{
+			To:      notification.Recipient,
+			Subject: fmt.Sprintf("New flat in house %d", payload.HouseId),
+			Body: fmt.Sprintf("Flat %d in house %d is now available: %d rooms, price %s.\n\nTo stop receiving these emails, follow %s",
+				payload.FlatNumber, payload.HouseId, payload.Rooms, models.FormatPrice(payload.Price, currency), link),
+		}, nil
+	default:
+		return Message{}, fmt.Errorf("unknown notification kind %q", notification.Kind)
+	}
+}
@@ -0,0 +1,48 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a single message. Implementations must be safe for
+// concurrent use.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewSenderFromEnv builds the sender selected by NOTIFICATION_SENDER: "smtp"
+// uses the SMTP_* variables, anything else falls back to a log sender that
+// writes to NOTIFICATION_LOG_FILE, or to the standard logger when unset.
+func NewSenderFromEnv() (Sender, error) {
+	switch os.Getenv("NOTIFICATION_SENDER") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %v", err)
+		}
+
+		return &SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}, nil
+	default:
+		path := os.Getenv("NOTIFICATION_LOG_FILE")
+		if path == "" {
+			return &LogSender{}, nil
+		}
+
+		return NewFileSender(path)
+	}
+}
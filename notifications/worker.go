@@ -0,0 +1,168 @@
+package notifications
+
+import (
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"context"
+	"log"
+	"time"
+)
+
+// Worker drains the notification outbox. Several workers may run against the
+// same database: rows are claimed with SKIP LOCKED and a lease, so each
+// notification is handed to exactly one of them at a time.
+type Worker struct {
+	Outbox       database.NotificationRepository
+	Sender       Sender
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxAttempts  int32
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// MarkTimeout bounds recording the outcome of a delivery, which is not
+	// cut short when Run is stopped.
+	MarkTimeout time.Duration
+}
+
+func NewWorker(outbox database.NotificationRepository, sender Sender) *Worker {
+	return &Worker{
+		Outbox:       outbox,
+		Sender:       sender,
+		BatchSize:    20,
+		PollInterval: 5 * time.Second,
+		Lease:        time.Minute,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		MarkTimeout:  10 * time.Second,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := w.RunOnce(ctx)
+			if err != nil {
+				log.Printf("Error processing notification outbox: %v", err)
+			}
+			if err != nil || processed < w.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and delivers a single batch, returning how many notifications
+// were claimed.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	notifications, err := w.Outbox.ClaimNotifications(ctx, w.BatchSize, w.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, notification := range notifications {
+		// The rest stay claimed until their lease runs out; sending them
+		// with a cancelled context would only count as failed attempts.
+		if ctx.Err() != nil {
+			break
+		}
+		w.deliver(ctx, notification)
+	}
+
+	return len(notifications), nil
+}
+
+func (w *Worker) deliver(ctx context.Context, notification models.Notification) {
+	message, err := Render(notification)
+	if err != nil {
+		// Rendering the same row again would fail the same way.
+		log.Printf("Notification %d dead-lettered, cannot render it: %v", notification.Id, err)
+		if err := w.markFailed(ctx, notification.Id, err, time.Now(), true); err != nil {
+			log.Printf("Error marking notification %d as failed: %v", notification.Id, err)
+		}
+		return
+	}
+
+	err = w.send(ctx, message)
+	if err == nil {
+		if err := w.markSent(ctx, notification.Id); err != nil {
+			log.Printf("Error marking notification %d as sent: %v", notification.Id, err)
+		}
+		return
+	}
+
+	attempts := notification.Attempts + 1
+	dead := attempts >= w.MaxAttempts
+	if dead {
+		log.Printf("Notification %d dead-lettered after %d attempts: %v", notification.Id, attempts, err)
+	}
+
+	nextAttemptAt := time.Now().Add(w.backoff(attempts))
+	if err := w.markFailed(ctx, notification.Id, err, nextAttemptAt, dead); err != nil {
+		log.Printf("Error marking notification %d as failed: %v", notification.Id, err)
+	}
+}
+
+// markContext keeps the values of ctx but not its cancellation: once a
+// message has been sent, stopping the worker must not keep it from being
+// marked, or it would be sent again when the lease runs out.
+func (w *Worker) markContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detached{ctx}, w.MarkTimeout)
+}
+
+func (w *Worker) markSent(ctx context.Context, id int32) error {
+	ctx, cancel := w.markContext(ctx)
+	defer cancel()
+
+	return w.Outbox.MarkNotificationSent(ctx, id)
+}
+
+func (w *Worker) markFailed(ctx context.Context, id int32, sendErr error, nextAttemptAt time.Time, dead bool) error {
+	ctx, cancel := w.markContext(ctx)
+	defer cancel()
+
+	return w.Outbox.MarkNotificationFailed(ctx, id, sendErr.Error(), nextAttemptAt, dead)
+}
+
+// detached is a context that is never cancelled but carries the values of
+// the one it wraps.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// send hands message to the Sender, giving up well before the lease runs out
+// so that another worker does not claim and deliver the notification again.
+func (w *Worker) send(ctx context.Context, message Message) error {
+	ctx, cancel := context.WithTimeout(ctx, w.Lease/2)
+	defer cancel()
+
+	return w.Sender.Send(ctx, message)
+}
+
+// backoff doubles the delay after every failed attempt, up to MaxBackoff.
+func (w *Worker) backoff(attempts int32) time.Duration {
+	delay := w.BaseBackoff
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= w.MaxBackoff {
+			return w.MaxBackoff
+		}
+	}
+
+	return delay
+}
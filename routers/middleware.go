@@ -0,0 +1,77 @@
+package routers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/auth"
+	"avito-backend-bootcamp/models"
+)
+
+// Role is the minimum level of access a route requires.
+type Role int
+
+const (
+	RolePublic Role = iota
+	RoleAuthenticated
+	RoleModerator
+)
+
+// Authenticate validates the access token in the Authorization header and
+// stores its claims under api.ClaimsContextKey. The standard "Bearer <token>"
+// scheme is expected; a bare token is still accepted for older clients.
+// Tokens found in revocations are rejected.
+func Authenticate(role Role, revocations auth.RevocationList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwtTokenStr := bearerToken(c.GetHeader("Authorization"))
+		if jwtTokenStr == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "Authorization token is required",
+				Code:  models.ERROR_UNAUTHORIZED,
+			})
+			return
+		}
+
+		claims, err := auth.ValidateJwtToken(c.Request.Context(), jwtTokenStr, revocations)
+		var checkErr *auth.RevocationCheckError
+		if errors.As(err, &checkErr) {
+			log.Printf("Error checking token revocation: %v", checkErr.Err)
+			api.AbortWithStorageError(c, checkErr.Err, "Failed to check authorization token")
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "Invalid authorization token",
+				Code:  models.ERROR_UNAUTHORIZED,
+			})
+			return
+		}
+
+		if role == RoleModerator && claims.UserType != string(models.MODERATOR) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Error: "Only moderator can access this resource",
+				Code:  models.ERROR_FORBIDDEN,
+			})
+			return
+		}
+
+		c.Set(api.ClaimsContextKey, claims)
+		c.Next()
+	}
+}
+
+func bearerToken(header string) string {
+	header = strings.TrimSpace(header)
+
+	scheme, token, found := strings.Cut(header, " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return header
+}
@@ -6,12 +6,14 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/auth"
 )
 
 type Route struct {
 	Name        string
 	Method      string
 	Pattern     string
+	Role        Role
 	HandlerFunc gin.HandlerFunc
 }
 
@@ -24,17 +26,23 @@ func NewRouterWithGinEngine(router *gin.Engine, handleFunctions ApiHandleFunctio
 		if route.HandlerFunc == nil {
 			route.HandlerFunc = DefaultHandleFunc
 		}
+
+		handlers := []gin.HandlerFunc{route.HandlerFunc}
+		if route.Role != RolePublic {
+			handlers = []gin.HandlerFunc{Authenticate(route.Role, handleFunctions.Revocations), route.HandlerFunc}
+		}
+
 		switch route.Method {
 		case http.MethodGet:
-			router.GET(route.Pattern, route.HandlerFunc)
+			router.GET(route.Pattern, handlers...)
 		case http.MethodPost:
-			router.POST(route.Pattern, route.HandlerFunc)
+			router.POST(route.Pattern, handlers...)
 		case http.MethodPut:
-			router.PUT(route.Pattern, route.HandlerFunc)
+			router.PUT(route.Pattern, handlers...)
 		case http.MethodPatch:
-			router.PATCH(route.Pattern, route.HandlerFunc)
+			router.PATCH(route.Pattern, handlers...)
 		case http.MethodDelete:
-			router.DELETE(route.Pattern, route.HandlerFunc)
+			router.DELETE(route.Pattern, handlers...)
 		}
 	}
 
@@ -49,6 +57,9 @@ type ApiHandleFunctions struct {
 	AuthOnlyAPI        api.AuthOnlyAPI
 	ModerationsOnlyAPI api.ModerationsOnlyAPI
 	NoAuthAPI          api.NoAuthAPI
+
+	// Revocations is consulted by Authenticate for every protected route.
+	Revocations auth.RevocationList
 }
 
 func getRoutes(handleFunctions ApiHandleFunctions) []Route {
@@ -57,49 +68,183 @@ func getRoutes(handleFunctions ApiHandleFunctions) []Route {
 			"FlatCreatePost",
 			http.MethodPost,
 			"/flat/create",
+			RoleAuthenticated,
 			handleFunctions.AuthOnlyAPI.FlatCreatePost,
 		},
+		{
+			"FlatStatusesGet",
+			http.MethodGet,
+			"/flat/statuses",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.FlatStatusesGet,
+		},
+		{
+			"FlatIdHistoryGet",
+			http.MethodGet,
+			"/flat/:id/history",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.FlatIdHistoryGet,
+		},
 		{
 			"HouseIdGet",
 			http.MethodGet,
 			"/house/:id",
+			RoleAuthenticated,
 			handleFunctions.AuthOnlyAPI.HouseIdGet,
 		},
+		{
+			"FlatIdPricesGet",
+			http.MethodGet,
+			"/flat/:id/prices",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.FlatIdPricesGet,
+		},
+		{
+			"FlatIdPatch",
+			http.MethodPatch,
+			"/flat/:id",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.FlatIdPatch,
+		},
+		{
+			"FlatIdDelete",
+			http.MethodDelete,
+			"/flat/:id",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.FlatIdDelete,
+		},
+		{
+			"HousesGet",
+			http.MethodGet,
+			"/houses",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.HousesGet,
+		},
 		{
 			"HouseIdSubscribePost",
 			http.MethodPost,
 			"/house/:id/subscribe",
+			RoleAuthenticated,
 			handleFunctions.AuthOnlyAPI.HouseIdSubscribePost,
 		},
+		{
+			"SubscriptionsGet",
+			http.MethodGet,
+			"/subscriptions",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.SubscriptionsGet,
+		},
+		{
+			"SubscriptionsIdDelete",
+			http.MethodDelete,
+			"/subscriptions/:id",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.SubscriptionsIdDelete,
+		},
+		{
+			"LogoutPost",
+			http.MethodPost,
+			"/logout",
+			RoleAuthenticated,
+			handleFunctions.AuthOnlyAPI.LogoutPost,
+		},
 		{
 			"FlatUpdatePost",
 			http.MethodPost,
 			"/flat/update",
+			RoleModerator,
 			handleFunctions.ModerationsOnlyAPI.FlatUpdatePost,
 		},
+		{
+			"FlatUpdateBulkPost",
+			http.MethodPost,
+			"/flat/update/bulk",
+			RoleModerator,
+			handleFunctions.ModerationsOnlyAPI.FlatUpdateBulkPost,
+		},
 		{
 			"HouseCreatePost",
 			http.MethodPost,
 			"/house/create",
+			RoleModerator,
 			handleFunctions.ModerationsOnlyAPI.HouseCreatePost,
 		},
+		{
+			"HouseIdPatch",
+			http.MethodPatch,
+			"/house/:id",
+			RoleModerator,
+			handleFunctions.ModerationsOnlyAPI.HouseIdPatch,
+		},
+		{
+			"HouseIdArchivePost",
+			http.MethodPost,
+			"/house/:id/archive",
+			RoleModerator,
+			handleFunctions.ModerationsOnlyAPI.HouseIdArchivePost,
+		},
+		{
+			"ModerationQueueGet",
+			http.MethodGet,
+			"/moderation/queue",
+			RoleModerator,
+			handleFunctions.ModerationsOnlyAPI.ModerationQueueGet,
+		},
+		{
+			"ModerationQueueAssignPost",
+			http.MethodPost,
+			"/moderation/queue/assign",
+			RoleModerator,
+			handleFunctions.ModerationsOnlyAPI.ModerationQueueAssignPost,
+		},
 		{
 			"DummyLoginGet",
 			http.MethodGet,
 			"/dummyLogin",
+			RolePublic,
 			handleFunctions.NoAuthAPI.DummyLoginGet,
 		},
 		{
 			"LoginPost",
 			http.MethodPost,
 			"/login",
+			RolePublic,
 			handleFunctions.NoAuthAPI.LoginPost,
 		},
 		{
 			"RegisterPost",
 			http.MethodPost,
 			"/register",
+			RolePublic,
 			handleFunctions.NoAuthAPI.RegisterPost,
 		},
+		{
+			"AuthRefreshPost",
+			http.MethodPost,
+			"/auth/refresh",
+			RolePublic,
+			handleFunctions.NoAuthAPI.AuthRefreshPost,
+		},
+		{
+			"JwksGet",
+			http.MethodGet,
+			"/.well-known/jwks.json",
+			RolePublic,
+			handleFunctions.NoAuthAPI.JwksGet,
+		},
+		{
+			"UnsubscribeGet",
+			http.MethodGet,
+			"/unsubscribe",
+			RolePublic,
+			handleFunctions.NoAuthAPI.UnsubscribeGet,
+		},
+		{
+			"UnsubscribePost",
+			http.MethodPost,
+			"/unsubscribe",
+			RolePublic,
+			handleFunctions.NoAuthAPI.UnsubscribePost,
+		},
 	}
 }
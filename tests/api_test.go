@@ -1,16 +1,20 @@
 package tests
 
 import (
+	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/auth"
 	"avito-backend-bootcamp/database"
 	"avito-backend-bootcamp/models"
 	"avito-backend-bootcamp/routers"
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,27 +47,69 @@ func getToken(router *gin.Engine, userType string) (string, error) {
 	return response["token"], nil
 }
 
+// store is in memory unless STORAGE=postgres selects the test database.
+var store database.Store
+
+// testDB is the test database under STORAGE=postgres, and nil otherwise.
+var testDB *sql.DB
+
+// newTestRouter wires the API to store the same way main does.
+func newTestRouter() *gin.Engine {
+	return routers.NewRouter(routers.ApiHandleFunctions{
+		AuthOnlyAPI: api.AuthOnlyAPI{
+			Houses:        store,
+			Flats:         store,
+			Subscriptions: store,
+			Tokens:        store,
+		},
+		ModerationsOnlyAPI: api.ModerationsOnlyAPI{
+			Houses: store,
+			Flats:  store,
+		},
+		NoAuthAPI: api.NoAuthAPI{
+			Users:         store,
+			Subscriptions: store,
+			Tokens:        store,
+		},
+		Revocations: store,
+	})
+}
+
 func TestMain(m *testing.M) {
-	os.Chdir("/app")
+	if os.Getenv("JWT_KEYS_DIR") == "" && os.Getenv("JWT_SECRET_KEY") == "" {
+		os.Setenv("JWT_SECRET_KEY", "test-secret")
+	}
+
+	err := auth.LoadKeys()
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	if os.Getenv("STORAGE") != "postgres" {
+		store = database.NewMemory()
+		os.Exit(m.Run())
+	}
 
-	err := database.InitTestDB()
+	config := database.ConfigFromEnv()
+	db, err := database.OpenTest(config)
 	if err != nil {
 		log.Fatalf("Failed to initialize test database: %v", err)
 	}
+	store = database.NewPostgres(db, config)
+	testDB = db
 
-	err = database.ClearTestDB()
+	err = database.ClearTestDB(db)
 	if err != nil {
 		log.Fatalf("Failed to clear test database: %v", err)
 	}
 
 	code := m.Run()
-	database.DB.Close()
+	db.Close()
 	os.Exit(code)
 }
 
 func TestHouseCreatePostModerator(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "moderator")
 	assert.NoError(t, err)
@@ -97,8 +143,7 @@ func TestHouseCreatePostModerator(t *testing.T) {
 }
 
 func TestHouseCreatePostClient(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "client")
 	assert.NoError(t, err)
@@ -123,12 +168,11 @@ func TestHouseCreatePostClient(t *testing.T) {
 	var response map[string]string
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "Only moderator can create house", response["error"])
+	assert.Equal(t, "Only moderator can access this resource", response["error"])
 }
 
 func TestFlatCreatePostModerator(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "moderator")
 	assert.NoError(t, err)
@@ -160,8 +204,7 @@ func TestFlatCreatePostModerator(t *testing.T) {
 }
 
 func TestFlatCreatePostClient(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "client")
 	assert.NoError(t, err)
@@ -192,13 +235,151 @@ func TestFlatCreatePostClient(t *testing.T) {
 	assert.Equal(t, payload.Rooms, response.Rooms)
 }
 
+func TestFlatCreatePostUnknownHouse(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/create", token, models.FlatCreatePostRequest{
+		HouseId:    999999,
+		FlatNumber: 1,
+		Price:      10000,
+		Rooms:      1,
+	})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response models.ErrorResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ERROR_HOUSE_NOT_FOUND, response.Code)
+}
+
+func TestFlatCreatePostDuplicate(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Duplicate street 1")
+	createFlat(t, router, moderator, house.Id, 202)
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/create", token, models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 202,
+		Price:      30303,
+		Rooms:      3,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response models.ErrorResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ERROR_FLAT_EXISTS, response.Code)
+}
+
+// A token can outlive its user; the stores then report ErrUserNotFound,
+// which must not surface as a 500.
+func TestUnknownUserIsUnauthorized(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Ghost street 1")
+	flat := createFlat(t, router, moderator, house.Id, 1)
+
+	ghost, err := auth.GenerateJwtToken(1000000, "ghost@example.com", string(models.MODERATOR))
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		path string
+		body interface{}
+	}{
+		{"create house", "/house/create", models.HouseCreatePostRequest{Address: "Ghost street 2", Year: 2000}},
+		{"create flat", "/flat/create", models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 2, Price: 5000, Rooms: 1}},
+		{"update flat", "/flat/update", models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(router, "POST", tc.path, ghost, tc.body)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			var response models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, models.ERROR_UNAUTHORIZED, response.Code)
+		})
+	}
+}
+
+func TestFlatCreatePostConcurrentDuplicates(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/house/create", token, models.HouseCreatePostRequest{
+		Address: "TestAddressConcurrent",
+		Year:    2024,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	err = json.Unmarshal(w.Body.Bytes(), &house)
+	assert.NoError(t, err)
+
+	payload := models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 303,
+		Price:      30303,
+		Rooms:      3,
+	}
+
+	codes := make(chan int, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- doRequest(router, "POST", "/flat/create", token, payload).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			created++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	assert.Equal(t, 1, created)
+}
+
 func TestFlatUpdatePostModerator(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "moderator")
 	assert.NoError(t, err)
 
+	claimPayload := models.FlatUpdatePostRequest{
+		Id:     1,
+		Status: models.ON_MODERATION,
+	}
+
+	claimPayloadBytes, _ := json.Marshal(claimPayload)
+	claimReq, _ := http.NewRequest("POST", "/flat/update", bytes.NewBuffer(claimPayloadBytes))
+	claimReq.Header.Set("Content-Type", "application/json")
+	claimReq.Header.Set("Authorization", token)
+
+	claimW := httptest.NewRecorder()
+	router.ServeHTTP(claimW, claimReq)
+
+	assert.Equal(t, http.StatusOK, claimW.Code)
+
 	payload := models.FlatUpdatePostRequest{
 		Id:     1,
 		Status: models.APPROVED,
@@ -222,8 +403,7 @@ func TestFlatUpdatePostModerator(t *testing.T) {
 }
 
 func TestHouseIdGetModerator(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "moderator")
 	assert.NoError(t, err)
@@ -244,8 +424,7 @@ func TestHouseIdGetModerator(t *testing.T) {
 }
 
 func TestHouseIdGetClient(t *testing.T) {
-	routes := routers.ApiHandleFunctions{}
-	router := routers.NewRouter(routes)
+	router := newTestRouter()
 
 	token, err := getToken(router, "client")
 	assert.NoError(t, err)
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/routers"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTokens(t *testing.T, router *gin.Engine, userType string) models.DummyLoginGet200Response {
+	w := doRequest(router, "GET", "/dummyLogin", "", map[string]string{"user_type": userType})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DummyLoginGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Token)
+	assert.NotEmpty(t, response.RefreshToken)
+	return response
+}
+
+func TestAuthRefreshPostRotation(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Rotation street 1")
+
+	tokens := getTokens(t, router, "client")
+
+	w := doRequest(router, "POST", "/auth/refresh", "", models.AuthRefreshPostRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var rotated models.DummyLoginGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+	assert.NotEmpty(t, rotated.Token)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	w = doRequest(router, "GET", "/house/"+itoa(house.Id), rotated.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Replaying the first token revokes the whole family, including the
+	// token it was rotated into.
+	w = doRequest(router, "POST", "/auth/refresh", "", models.AuthRefreshPostRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(router, "POST", "/auth/refresh", "", models.AuthRefreshPostRequest{RefreshToken: rotated.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogoutPost(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	path := "/house/" + itoa(createHouse(t, router, moderator, "Logout street 1").Id)
+
+	tokens := getTokens(t, router, "client")
+
+	w := doRequest(router, "GET", path, tokens.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/logout", tokens.Token, models.LogoutPostRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", path, tokens.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(router, "POST", "/auth/refresh", "", models.AuthRefreshPostRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestBearerAuthorization(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	path := "/house/" + itoa(createHouse(t, router, moderator, "Bearer street 1").Id)
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "GET", path, "Bearer "+token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", path, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(router, "GET", path, "Bearer invalid", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", "Bearer "+token, models.FlatUpdatePostRequest{Id: 1, Status: models.APPROVED})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestFlatCreatePostRecordsAuthor(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Author street 1")
+
+	first, err := getToken(router, "client")
+	assert.NoError(t, err)
+	second, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/create", first, models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 303,
+		Price:      30303,
+		Rooms:      3,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var flat models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &flat))
+	assert.NotNil(t, flat.CreatedBy)
+
+	// dummyLogin keeps issuing tokens for the same user.
+	w = doRequest(router, "POST", "/flat/create", second, models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 304,
+		Price:      30404,
+		Rooms:      3,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var other models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &other))
+	assert.Equal(t, flat.CreatedBy, other.CreatedBy)
+}
+
+// failingRevocations cannot be consulted.
+type failingRevocations struct {
+	err error
+}
+
+func (r failingRevocations) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, r.err
+}
+
+// failingUsers cannot look accounts up.
+type failingUsers struct {
+	database.UserRepository
+	err error
+}
+
+func (u failingUsers) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, u.err
+}
+
+func TestStorageFailuresAreNotAuthFailures(t *testing.T) {
+	tokens := getTokens(t, newTestRouter(), "client")
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, models.ERROR_TIMEOUT},
+		{"unavailable", errors.New("connection refused"), http.StatusInternalServerError, models.ERROR_INTERNAL},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := routers.NewRouter(routers.ApiHandleFunctions{
+				AuthOnlyAPI: api.AuthOnlyAPI{Houses: store, Flats: store, Subscriptions: store, Tokens: store},
+				NoAuthAPI:   api.NoAuthAPI{Users: failingUsers{err: tc.err}, Subscriptions: store, Tokens: store},
+				Revocations: failingRevocations{err: tc.err},
+			})
+
+			var response models.ErrorResponse
+			w := doRequest(router, "GET", "/houses", tokens.Token, nil)
+			assert.Equal(t, tc.status, w.Code)
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.code, response.Code)
+
+			w = doRequest(router, "POST", "/login", "", models.LoginPostRequest{Email: "someone@example.com", Password: "password1"})
+			assert.Equal(t, tc.status, w.Code)
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.code, response.Code)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/moderation"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testModerationRules = `
+decline:
+  - name: implausible-price
+    field: price
+    currency: rub
+    max: 100000000000
+    reason: implausible price
+  - field: rooms
+    min: 1
+    max: 20
+    reason: implausible number of rooms
+  - name: duplicate-flat-number
+    field: flat_number
+    duplicate: true
+    reason: flat number already listed
+trusted_developers:
+  - name: Trusted Test Developer
+    accounts:
+      - Trusted-Account@example.com
+`
+
+func TestFlatCreatePostAutoModeration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(testModerationRules), 0o600))
+
+	t.Setenv("MODERATION_RULES_FILE", path)
+	assert.NoError(t, moderation.LoadRules())
+	defer func() {
+		os.Unsetenv("MODERATION_RULES_FILE")
+		moderation.LoadRules()
+	}()
+
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	trusted := registerAndLogin(t, router, "trusted-account@example.com", models.CLIENT)
+	stranger := registerAndLogin(t, router, "trusted-house-stranger@example.com", models.CLIENT)
+
+	plain := createHouse(t, router, moderator, "Moderated Street 1")
+
+	developer := "trusted test developer"
+	w := doRequest(router, "POST", "/house/create", moderator, models.HouseCreatePostRequest{
+		Address:   "Trusted Street 1",
+		Year:      2020,
+		Developer: &developer,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: plain.Id, FlatNumber: 701, Price: 200000000000, Rooms: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var declined models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &declined))
+	assert.Equal(t, models.DECLINED, declined.Status)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(declined.Id)+"/history", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var history models.FlatIdHistoryGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	if assert.Equal(t, 1, len(history.History)) {
+		assert.Equal(t, models.DECLINED, history.History[0].NewStatus)
+		assert.True(t, models.CanTransition(models.ACTOR_RULES, history.History[0].PreviousStatus, history.History[0].NewStatus))
+		assert.Nil(t, history.History[0].ActorId)
+		assert.Equal(t, "implausible price (rule implausible-price)", *history.History[0].Reason)
+	}
+
+	// Price rules only apply to flats priced in their currency.
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: plain.Id, FlatNumber: 703, Price: 200000000000, Currency: "USD", Rooms: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var foreign models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &foreign))
+	assert.Equal(t, models.CREATED, foreign.Status)
+
+	w = doRequest(router, "POST", "/flat/create", trusted, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 1, Price: 5000, Rooms: 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var approved models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &approved))
+	assert.Equal(t, models.APPROVED, approved.Status)
+
+	w = doRequest(router, "POST", "/flat/create", trusted, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 2, Price: 5000, Rooms: 40})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var absurd models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &absurd))
+	assert.Equal(t, models.DECLINED, absurd.Status)
+
+	// Other users posting into a trusted developer's house are moderated.
+	w = doRequest(router, "POST", "/flat/create", stranger, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 3, Price: 5000, Rooms: 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var untrusted models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &untrusted))
+	assert.Equal(t, models.CREATED, untrusted.Status)
+
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: plain.Id, FlatNumber: 702, Price: 5000, Rooms: 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var regular models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &regular))
+	assert.Equal(t, models.CREATED, regular.Status)
+
+	w = doRequest(router, "POST", "/flat/create", stranger, models.FlatCreatePostRequest{HouseId: plain.Id, FlatNumber: 702, Price: 6000, Rooms: 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var duplicate models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &duplicate))
+	assert.Equal(t, models.DECLINED, duplicate.Status)
+	assert.NotEqual(t, regular.Id, duplicate.Id)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(duplicate.Id)+"/history", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	history = models.FlatIdHistoryGet200Response{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	if assert.Equal(t, 1, len(history.History)) {
+		assert.Equal(t, models.DECLINED, history.History[0].NewStatus)
+		assert.Equal(t, "flat number already listed (rule duplicate-flat-number)", *history.History[0].Reason)
+	}
+
+	// Reopening the duplicate would take the number again.
+	price := int64(5500)
+	w = doRequest(router, "PATCH", "/flat/"+itoa(duplicate.Id), stranger, models.FlatIdPatchRequest{Price: &price})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Declined flats do not take their number.
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: plain.Id, FlatNumber: 701, Price: 5000, Rooms: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var relisted models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &relisted))
+	assert.Equal(t, models.CREATED, relisted.Status)
+}
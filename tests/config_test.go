@@ -0,0 +1,30 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/database"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "7")
+	t.Setenv("DB_QUERY_TIMEOUT", "250ms")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "not a duration")
+
+	config := database.ConfigFromEnv()
+
+	assert.Equal(t, 7, config.MaxOpenConns)
+	assert.Equal(t, 250*time.Millisecond, config.QueryTimeout)
+	assert.Equal(t, 30*time.Minute, config.ConnMaxLifetime)
+}
+
+func TestIsCanceled(t *testing.T) {
+	assert.True(t, database.IsCanceled(context.DeadlineExceeded))
+	assert.True(t, database.IsCanceled(fmt.Errorf("query: %w", context.Canceled)))
+	assert.False(t, database.IsCanceled(errors.New("connection refused")))
+}
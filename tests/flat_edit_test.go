@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatIdPatch(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	author := registerAndLogin(t, router, "flat-author@example.com", models.CLIENT)
+	stranger := registerAndLogin(t, router, "flat-stranger@example.com", models.CLIENT)
+	house := createHouse(t, router, moderator, "TestAddressPatch")
+
+	flat := createFlat(t, router, author, house.Id, 701)
+	createFlat(t, router, author, house.Id, 702)
+
+	w := doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.APPROVED})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	price := int64(2500)
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), stranger, models.FlatIdPatchRequest{Price: &price})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A moderator's edit keeps the flat approved.
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), moderator, models.FlatIdPatchRequest{Price: &price})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var edited models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &edited))
+	assert.Equal(t, price, edited.Price)
+	assert.Equal(t, models.APPROVED, edited.Status)
+
+	// Its author's edit sends it back to moderation.
+	rooms := int32(3)
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), author, models.FlatIdPatchRequest{Rooms: &rooms})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &edited))
+	assert.Equal(t, rooms, edited.Rooms)
+	assert.Equal(t, price, edited.Price)
+	assert.Equal(t, models.CREATED, edited.Status)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(flat.Id)+"/history", author, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var history models.FlatIdHistoryGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	last := history.History[len(history.History)-1]
+	assert.Equal(t, models.APPROVED, last.PreviousStatus)
+	assert.Equal(t, models.CREATED, last.NewStatus)
+	assert.True(t, models.CanTransition(models.ACTOR_AUTHOR, last.PreviousStatus, last.NewStatus))
+
+	number := int32(702)
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), author, models.FlatIdPatchRequest{FlatNumber: &number})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestFlatIdDelete(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	author := registerAndLogin(t, router, "withdrawing-author@example.com", models.CLIENT)
+	stranger := registerAndLogin(t, router, "withdrawing-stranger@example.com", models.CLIENT)
+	house := createHouse(t, router, moderator, "TestAddressDelete")
+
+	flat := createFlat(t, router, author, house.Id, 711)
+
+	w := doRequest(router, "DELETE", "/flat/"+itoa(flat.Id), stranger, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(router, "DELETE", "/flat/"+itoa(flat.Id), author, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "DELETE", "/flat/"+itoa(flat.Id), author, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(flat.Id)+"/history", author, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The withdrawn flat's number is free again.
+	createFlat(t, router, author, house.Id, 711)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHouseIdPatch(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	house := createHouse(t, router, moderator, "Tverskaya strret 1")
+
+	address := "Tverskaya street 1"
+	developer := "Late Developer"
+	w := doRequest(router, "PATCH", "/house/"+itoa(house.Id), client, models.HouseIdPatchRequest{Address: &address})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(router, "PATCH", "/house/"+itoa(house.Id), moderator, models.HouseIdPatchRequest{
+		Address:   &address,
+		Developer: &developer,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var edited models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &edited))
+	assert.Equal(t, address, edited.Address)
+	assert.Equal(t, developer, *edited.Developer)
+	assert.Equal(t, house.Year, edited.Year)
+	assert.True(t, edited.UpdateAt.After(house.UpdateAt))
+
+	empty := " "
+	w = doRequest(router, "PATCH", "/house/"+itoa(house.Id), moderator, models.HouseIdPatchRequest{Address: &empty})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "PATCH", "/house/999999", moderator, models.HouseIdPatchRequest{Address: &address})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHouseIdArchivePost(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	house := createHouse(t, router, moderator, "Archived lane 7")
+	flat := createFlat(t, router, moderator, house.Id, 1)
+
+	w := doRequest(router, "POST", "/house/"+itoa(house.Id)+"/archive", client, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(router, "POST", "/house/"+itoa(house.Id)+"/archive", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var archived models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &archived))
+	assert.NotNil(t, archived.ArchivedAt)
+
+	w = doRequest(router, "GET", "/house/"+itoa(house.Id), client, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "GET", "/houses?q=archived+lane", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var houses models.HousesGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &houses))
+	assert.Equal(t, 0, len(houses.Houses))
+
+	w = doRequest(router, "GET", "/house/"+itoa(house.Id), moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page models.HouseIdGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Flats))
+	assert.Equal(t, flat.Id, page.Flats[0].Id)
+
+	w = doRequest(router, "GET", "/houses?q=archived+lane", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &houses))
+	assert.Equal(t, 1, len(houses.Houses))
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHouseIdGetFiltersAndPages(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/house/create", moderator, models.HouseCreatePostRequest{
+		Address: "TestAddressPages",
+		Year:    2024,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+
+	for i, price := range []int64{3000, 1000, 2000, 4000} {
+		w := doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{
+			HouseId:    house.Id,
+			FlatNumber: int32(i + 1),
+			Price:      price,
+			Rooms:      int32(i%2 + 1),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	path := "/house/" + itoa(house.Id)
+
+	w = doRequest(router, "GET", path+"?sort=price&currency=RUB&order=desc&limit=3", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page models.HouseIdGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 3, len(page.Flats))
+	assert.Equal(t, int64(4000), page.Flats[0].Price)
+	assert.Equal(t, int64(2000), page.Flats[2].Price)
+	assert.NotEmpty(t, page.NextCursor)
+
+	w = doRequest(router, "GET", path+"?sort=price&currency=RUB&order=desc&limit=3&cursor="+page.NextCursor, moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var next models.HouseIdGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+	assert.Equal(t, 1, len(next.Flats))
+	assert.Equal(t, int64(1000), next.Flats[0].Price)
+	assert.Empty(t, next.NextCursor)
+
+	w = doRequest(router, "GET", path+"?sort=rooms&cursor="+page.NextCursor, moderator, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "GET", path+"?rooms=2&price_min=2000&currency=RUB&status=created", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Flats))
+	assert.Equal(t, int32(4), page.Flats[0].FlatNumber)
+
+	// Prices in different currencies are never compared.
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 5,
+		Price:      5000,
+		Currency:   "usd",
+		Rooms:      2,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", path+"?price_min=2000&currency=usd", moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Flats))
+	assert.Equal(t, int32(5), page.Flats[0].FlatNumber)
+
+	for _, query := range []string{"?price_max=2000", "?sort=price", "?rooms=2&price_min=1"} {
+		w = doRequest(router, "GET", path+query, moderator, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, map[string]string{"currency": models.FIELD_REQUIRED}, fieldCodes(t, w.Body.Bytes()))
+	}
+
+	w = doRequest(router, "GET", path+"?sort=price&currency=rubles", moderator, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"currency": models.FIELD_INVALID_CURRENCY}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "GET", path+"?status=created", client, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(router, "GET", path+"?sort=address", moderator, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
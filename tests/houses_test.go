@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHousesGetSearch(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	developer := "Catalogue Builders"
+	var houses []models.House
+	for _, year := range []int32{1990, 2005, 2020} {
+		w := doRequest(router, "POST", "/house/create", moderator, models.HouseCreatePostRequest{
+			Address:   "Lesnaya street, " + itoa(year),
+			Year:      year,
+			Developer: &developer,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var house models.House
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+		houses = append(houses, house)
+	}
+
+	// Adding a flat bumps update_at, so the oldest house is now the most
+	// recently updated one.
+	flat := createFlat(t, router, moderator, houses[0].Id, 1)
+	w := doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.APPROVED})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/houses?q=lesnaya+catalogue&limit=2", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page models.HousesGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 2, len(page.Houses))
+	assert.Equal(t, houses[0].Id, page.Houses[0].Id)
+	assert.Equal(t, houses[2].Id, page.Houses[1].Id)
+	assert.NotEmpty(t, page.NextCursor)
+
+	w = doRequest(router, "GET", "/houses?q=lesnaya+catalogue&limit=2&cursor="+page.NextCursor, client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var next models.HousesGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+	assert.Equal(t, 1, len(next.Houses))
+	assert.Equal(t, houses[1].Id, next.Houses[0].Id)
+	assert.Empty(t, next.NextCursor)
+
+	w = doRequest(router, "GET", "/houses?q=catalogue&year_min=2000&year_max=2010", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Houses))
+	assert.Equal(t, houses[1].Id, page.Houses[0].Id)
+
+	w = doRequest(router, "GET", "/houses?q=catalogue&has_approved_flats=true", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Houses))
+	assert.Equal(t, houses[0].Id, page.Houses[0].Id)
+
+	w = doRequest(router, "GET", "/houses?q=catalogue+nowhere", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 0, len(page.Houses))
+
+	w = doRequest(router, "GET", "/houses?has_approved_flats=maybe", client, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/auth"
+	"avito-backend-bootcamp/models"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useKeys loads the keys of dir for the rest of the test and restores the
+// keys of TestMain afterwards.
+func useKeys(t *testing.T, dir, activeKid string) error {
+	t.Cleanup(func() {
+		if err := auth.LoadKeys(); err != nil {
+			t.Fatalf("Failed to restore JWT keys: %v", err)
+		}
+	})
+	return reloadKeys(t, dir, activeKid)
+}
+
+// reloadKeys loads the keys of dir again after useKeys.
+func reloadKeys(t *testing.T, dir, activeKid string) error {
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_ACTIVE_KID", activeKid)
+	t.Setenv("JWT_SECRET_KEY", "")
+	return auth.LoadKeys()
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// writeRSAKey writes "<kid>.pem" in PKCS #1 and returns its public key.
+func writeRSAKey(t *testing.T, dir, kid string) *rsa.PublicKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, kid+".pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	return &key.PublicKey
+}
+
+// writeECKey writes "<kid>.pem" in SEC 1 and returns its public key.
+func writeECKey(t *testing.T, dir, kid string) *ecdsa.PublicKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, kid+".pem"), "EC PRIVATE KEY", der)
+	return &key.PublicKey
+}
+
+// writePKCS8Key writes "<kid>.pem" in PKCS #8.
+func writePKCS8Key(t *testing.T, dir, kid string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, kid+".pem"), "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, dir, kid string, public interface{}) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, kid+".pub.pem"), "PUBLIC KEY", der)
+}
+
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &auth.Claims{})
+	require.NoError(t, err)
+	return parsed.Header
+}
+
+func TestLoadKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		write     func(t *testing.T, dir string)
+		activeKid string
+		alg       string
+		kid       string
+		fails     bool
+	}{
+		{
+			name:  "rsa",
+			write: func(t *testing.T, dir string) { writeRSAKey(t, dir, "rsa-1") },
+			alg:   "RS256",
+			kid:   "rsa-1",
+		},
+		{
+			name:  "ec",
+			write: func(t *testing.T, dir string) { writeECKey(t, dir, "ec-1") },
+			alg:   "ES256",
+			kid:   "ec-1",
+		},
+		{
+			name:  "pkcs8",
+			write: func(t *testing.T, dir string) { writePKCS8Key(t, dir, "pkcs8-1") },
+			alg:   "ES256",
+			kid:   "pkcs8-1",
+		},
+		{
+			name: "active key picked among several",
+			write: func(t *testing.T, dir string) {
+				writeRSAKey(t, dir, "rsa-1")
+				writeECKey(t, dir, "ec-1")
+			},
+			activeKid: "ec-1",
+			alg:       "ES256",
+			kid:       "ec-1",
+		},
+		{
+			name: "several keys without an active one",
+			write: func(t *testing.T, dir string) {
+				writeRSAKey(t, dir, "rsa-1")
+				writeECKey(t, dir, "ec-1")
+			},
+			fails: true,
+		},
+		{
+			name: "public key never signs",
+			write: func(t *testing.T, dir string) {
+				writePublicKey(t, dir, "retired", writeRSAKey(t, t.TempDir(), "retired"))
+			},
+			activeKid: "retired",
+			fails:     true,
+		},
+		{
+			name: "only public keys",
+			write: func(t *testing.T, dir string) {
+				writePublicKey(t, dir, "retired", writeRSAKey(t, t.TempDir(), "retired"))
+			},
+			fails: true,
+		},
+		{
+			name:      "unknown active key",
+			write:     func(t *testing.T, dir string) { writeRSAKey(t, dir, "rsa-1") },
+			activeKid: "rsa-2",
+			fails:     true,
+		},
+		{
+			name: "not a key",
+			write: func(t *testing.T, dir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "junk.pem"), []byte("junk"), 0o600))
+			},
+			fails: true,
+		},
+		{
+			name:  "no keys",
+			write: func(t *testing.T, dir string) {},
+			fails: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.write(t, dir)
+
+			err := useKeys(t, dir, tc.activeKid)
+			if tc.fails {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			token, err := auth.GenerateJwtToken(1, "keys@example.com", "client")
+			require.NoError(t, err)
+			header := tokenHeader(t, token)
+			assert.Equal(t, tc.alg, header["alg"])
+			assert.Equal(t, tc.kid, header["kid"])
+
+			claims, err := auth.ValidateJwtToken(context.Background(), token, store)
+			require.NoError(t, err)
+			assert.Equal(t, "keys@example.com", claims.Email)
+		})
+	}
+}
+
+func TestLoadKeysRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := writeRSAKey(t, dir, "old")
+	require.NoError(t, useKeys(t, dir, "old"))
+
+	oldToken, err := auth.GenerateJwtToken(1, "rotation@example.com", "client")
+	require.NoError(t, err)
+
+	// The old key is retired: only its public half is kept, and a new key
+	// signs from now on.
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pem")))
+	writePublicKey(t, dir, "old", oldKey)
+	writeECKey(t, dir, "new")
+	require.NoError(t, reloadKeys(t, dir, "new"))
+
+	_, err = auth.ValidateJwtToken(context.Background(), oldToken, store)
+	assert.NoError(t, err)
+
+	newToken, err := auth.GenerateJwtToken(1, "rotation@example.com", "client")
+	require.NoError(t, err)
+	assert.Equal(t, "new", tokenHeader(t, newToken)["kid"])
+	_, err = auth.ValidateJwtToken(context.Background(), newToken, store)
+	assert.NoError(t, err)
+
+	// Once the public key is gone too, the old tokens stop validating.
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pub.pem")))
+	require.NoError(t, reloadKeys(t, dir, "new"))
+	_, err = auth.ValidateJwtToken(context.Background(), oldToken, store)
+	assert.Error(t, err)
+}
+
+func TestValidateJwtTokenRejectsMismatchedAlg(t *testing.T) {
+	dir := t.TempDir()
+	public := writeRSAKey(t, dir, "rsa-1")
+	require.NoError(t, useKeys(t, dir, ""))
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	claims := &auth.Claims{
+		UserID:         1,
+		Email:          "forger@example.com",
+		UserType:       string(models.MODERATOR),
+		StandardClaims: jwt.StandardClaims{Id: "forged"},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method jwt.SigningMethod
+		key    interface{}
+	}{
+		// The classic confusion: an HMAC keyed with the published RSA key.
+		{"hs256 with the public key", jwt.SigningMethodHS256, publicPEM},
+		{"none", jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			forged := jwt.NewWithClaims(tc.method, claims)
+			forged.Header["kid"] = "rsa-1"
+			token, err := forged.SignedString(tc.key)
+			require.NoError(t, err)
+
+			_, err = auth.ValidateJwtToken(context.Background(), token, store)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJwksGet(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "rsa-1")
+	ecKey := writeECKey(t, dir, "ec-1")
+	writePublicKey(t, dir, "retired", writeRSAKey(t, t.TempDir(), "retired"))
+	require.NoError(t, useKeys(t, dir, "rsa-1"))
+	// The HS256 secret verifies tokens too but must never be published.
+	t.Setenv("JWT_SECRET_KEY", "jwks-secret")
+	require.NoError(t, auth.LoadKeys())
+
+	w := doRequest(newTestRouter(), "GET", "/.well-known/jwks.json", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.JwksGet200Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	jwks := map[string]models.Jwk{}
+	for _, jwk := range response.Keys {
+		jwks[jwk.Kid] = jwk
+	}
+	require.Equal(t, 3, len(jwks))
+	assert.NotContains(t, jwks, "secret")
+
+	rsaJwk := jwks["rsa-1"]
+	assert.Equal(t, "RSA", rsaJwk.Kty)
+	assert.Equal(t, "RS256", rsaJwk.Alg)
+	assert.Equal(t, "sig", rsaJwk.Use)
+	assert.Equal(t, "AQAB", rsaJwk.E)
+	assert.Equal(t, rsaKey.N.Bytes(), decodeBase64URL(t, rsaJwk.N))
+
+	ecJwk := jwks["ec-1"]
+	assert.Equal(t, "EC", ecJwk.Kty)
+	assert.Equal(t, "ES256", ecJwk.Alg)
+	assert.Equal(t, "P-256", ecJwk.Crv)
+	assert.Equal(t, ecKey.X.FillBytes(make([]byte, 32)), decodeBase64URL(t, ecJwk.X))
+	assert.Equal(t, ecKey.Y.FillBytes(make([]byte, 32)), decodeBase64URL(t, ecJwk.Y))
+
+	assert.Equal(t, "RS256", jwks["retired"].Alg)
+}
+
+func decodeBase64URL(t *testing.T, value string) []byte {
+	decoded, err := jwt.DecodeSegment(value)
+	require.NoError(t, err)
+	return decoded
+}
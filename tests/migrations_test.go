@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/migrations"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsLoad(t *testing.T) {
+	loaded, err := migrations.Load()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, loaded)
+
+	for i, migration := range loaded {
+		assert.Equal(t, i+1, migration.Version, "migration versions must have no gaps")
+		assert.NotEmpty(t, migration.Up, migration.String())
+		assert.NotEmpty(t, migration.Down, migration.String())
+	}
+}
+
+// appliedVersions reads schema_migrations in version order.
+func appliedVersions(t *testing.T) []int {
+	rows, err := testDB.Query("SELECT version FROM schema_migrations ORDER BY version")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	versions := []int{}
+	for rows.Next() {
+		var version int
+		require.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	require.NoError(t, rows.Err())
+	return versions
+}
+
+// versionsUpTo returns 1, 2, ..., last.
+func versionsUpTo(last int) []int {
+	versions := []int{}
+	for version := 1; version <= last; version++ {
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestMigrationsUpDownTo(t *testing.T) {
+	if testDB == nil {
+		t.Skip("needs STORAGE=postgres")
+	}
+
+	loaded, err := migrations.Load()
+	require.NoError(t, err)
+	latest := loaded[len(loaded)-1].Version
+
+	// Down scripts restore constraints the data of earlier tests may break.
+	require.NoError(t, database.ClearTestDB(testDB))
+	t.Cleanup(func() {
+		if err := migrations.Up(testDB); err != nil {
+			t.Fatalf("Failed to migrate the test database back up: %v", err)
+		}
+	})
+
+	require.NoError(t, migrations.Up(testDB))
+	assert.Equal(t, versionsUpTo(latest), appliedVersions(t))
+
+	require.NoError(t, migrations.Down(testDB))
+	assert.Equal(t, versionsUpTo(latest-1), appliedVersions(t))
+
+	require.NoError(t, migrations.To(testDB, 9))
+	assert.Equal(t, versionsUpTo(9), appliedVersions(t))
+
+	assert.Error(t, migrations.To(testDB, latest+1))
+	assert.Equal(t, versionsUpTo(9), appliedVersions(t))
+
+	// Replicas starting together take turns on the advisory lock, so each
+	// migration is applied exactly once and none of them fails.
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = migrations.Up(testDB)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, versionsUpTo(latest), appliedVersions(t))
+
+	// Every down script runs, and the schema can be built again from scratch.
+	require.NoError(t, migrations.To(testDB, 0))
+	assert.Empty(t, appliedVersions(t))
+
+	require.NoError(t, migrations.To(testDB, latest))
+	assert.Equal(t, versionsUpTo(latest), appliedVersions(t))
+}
@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/api"
+	"avito-backend-bootcamp/database"
+	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/routers"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerAndLogin(t *testing.T, router *gin.Engine, email string, userType models.UserType) string {
+	w := doRequest(router, "POST", "/register", "", models.RegisterPostRequest{
+		Email:    email,
+		Password: "Password123",
+		UserType: userType,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/login", "", models.LoginPostRequest{
+		Email:    email,
+		Password: "Password123",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DummyLoginGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Token
+}
+
+func createHouse(t *testing.T, router *gin.Engine, token, address string) models.House {
+	w := doRequest(router, "POST", "/house/create", token, models.HouseCreatePostRequest{
+		Address: address,
+		Year:    2024,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+	return house
+}
+
+func createFlat(t *testing.T, router *gin.Engine, token string, houseId, flatNumber int32) models.Flat {
+	w := doRequest(router, "POST", "/flat/create", token, models.FlatCreatePostRequest{
+		HouseId:    houseId,
+		FlatNumber: flatNumber,
+		Price:      1000,
+		Rooms:      1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var flat models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &flat))
+	return flat
+}
+
+func TestFlatUpdatePostClaim(t *testing.T) {
+	router := newTestRouter()
+
+	first := registerAndLogin(t, router, "first-moderator@example.com", models.MODERATOR)
+	second := registerAndLogin(t, router, "second-moderator@example.com", models.MODERATOR)
+
+	house := createHouse(t, router, first, "Claim street 1")
+	flat := createFlat(t, router, first, house.Id, 501)
+
+	w := doRequest(router, "POST", "/flat/update", first, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var claimed models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &claimed))
+	assert.NotNil(t, claimed.ClaimedBy)
+	assert.NotNil(t, claimed.ClaimExpiresAt)
+
+	w = doRequest(router, "POST", "/flat/update", second, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", second, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.DECLINED})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", first, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.APPROVED})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var approved models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &approved))
+	assert.Equal(t, models.APPROVED, approved.Status)
+	assert.Nil(t, approved.ClaimedBy)
+}
+
+func TestFlatUpdatePostUnknownFlat(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: 999999, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFlatUpdatePostTransitions(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	house := createHouse(t, router, token, "Transitions street 1")
+	flat := createFlat(t, router, token, house.Id, 502)
+
+	var response models.ErrorResponse
+
+	w := doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: flat.Id})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"status": models.FIELD_REQUIRED}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.APPROVED})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ERROR_INVALID_STATUS_TRANSITION, response.Code)
+
+	w = doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.DECLINED})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", token, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.CREATED})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ERROR_INVALID_STATUS_TRANSITION, response.Code)
+}
+
+func TestFlatStatusesGet(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "GET", "/flat/statuses", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FlatStatusesGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.StatusTransitions, response.Transitions)
+}
+
+func TestFlatIdHistoryGet(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	author, err := getToken(router, "client")
+	assert.NoError(t, err)
+	stranger := registerAndLogin(t, router, "history-stranger@example.com", models.CLIENT)
+
+	house := createHouse(t, router, moderator, "History street 1")
+	flat := createFlat(t, router, author, house.Id, 503)
+
+	w := doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	reason := "Photos do not match the address"
+	w = doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.DECLINED, Reason: &reason})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	path := "/flat/" + itoa(flat.Id) + "/history"
+
+	w = doRequest(router, "GET", path, author, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FlatIdHistoryGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 2, len(response.History))
+	assert.Equal(t, models.CREATED, response.History[0].PreviousStatus)
+	assert.Equal(t, models.ON_MODERATION, response.History[0].NewStatus)
+	assert.Equal(t, models.DECLINED, response.History[1].NewStatus)
+	assert.Equal(t, reason, *response.History[1].Reason)
+	assert.NotNil(t, response.History[1].ActorId)
+
+	w = doRequest(router, "GET", path, moderator, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", path, stranger, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestModerationQueue(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	developer := "QueueDeveloper"
+	w := doRequest(router, "POST", "/house/create", token, models.HouseCreatePostRequest{
+		Address:   "TestAddressQueue",
+		Year:      2024,
+		Developer: &developer,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+
+	first := createFlat(t, router, token, house.Id, 1)
+	second := createFlat(t, router, token, house.Id, 2)
+	third := createFlat(t, router, token, house.Id, 3)
+
+	w = doRequest(router, "GET", "/moderation/queue?developer=queuedeveloper&limit=2", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page models.ModerationQueueGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 2, len(page.Flats))
+	assert.Equal(t, first.Id, page.Flats[0].Id)
+	assert.Equal(t, second.Id, page.Flats[1].Id)
+	assert.NotEmpty(t, page.NextCursor)
+
+	w = doRequest(router, "GET", "/moderation/queue?developer=queuedeveloper&limit=2&cursor="+page.NextCursor, token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var next models.ModerationQueueGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+	assert.Equal(t, 1, len(next.Flats))
+	assert.Equal(t, third.Id, next.Flats[0].Id)
+	assert.Empty(t, next.NextCursor)
+
+	w = doRequest(router, "POST", "/moderation/queue/assign", token, models.ModerationQueueAssignPostRequest{
+		Count:   2,
+		HouseId: &house.Id,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var assigned models.ModerationQueueAssignPost200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &assigned))
+	assert.Equal(t, 2, len(assigned.Flats))
+	assert.Equal(t, first.Id, assigned.Flats[0].Id)
+	assert.Equal(t, models.ON_MODERATION, assigned.Flats[0].Status)
+
+	w = doRequest(router, "POST", "/moderation/queue/assign", token, models.ModerationQueueAssignPostRequest{
+		Count:   2,
+		HouseId: &house.Id,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &assigned))
+	assert.Equal(t, 1, len(assigned.Flats))
+	assert.Equal(t, third.Id, assigned.Flats[0].Id)
+
+	w = doRequest(router, "GET", "/moderation/queue?house_id="+itoa(house.Id), token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 0, len(page.Flats))
+}
+
+func TestFlatUpdateBulkPost(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	house := createHouse(t, router, token, "Bulk street 1")
+	first := createFlat(t, router, token, house.Id, 601)
+	second := createFlat(t, router, token, house.Id, 602)
+
+	// Approving a flat that is not on moderation fails, which rolls back
+	// the whole atomic batch.
+	w := doRequest(router, "POST", "/flat/update/bulk", token, models.FlatUpdateBulkPostRequest{
+		Items: []models.FlatUpdateBulkItem{
+			{Id: first.Id, Status: models.ON_MODERATION},
+			{Id: second.Id, Status: models.APPROVED},
+		},
+		Mode: models.BULK_ATOMIC,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response models.FlatUpdateBulkPost200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 2, len(response.Results))
+	assert.Equal(t, models.ERROR_ROLLED_BACK, response.Results[0].Error.Code)
+	assert.Equal(t, models.ERROR_INVALID_STATUS_TRANSITION, response.Results[1].Error.Code)
+
+	w = doRequest(router, "GET", "/moderation/queue?house_id="+itoa(house.Id)+"&limit=100", token, nil)
+	var queue models.ModerationQueueGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	var queued []int32
+	for _, flat := range queue.Flats {
+		queued = append(queued, flat.Id)
+	}
+	assert.Contains(t, queued, first.Id)
+
+	// In best-effort mode the valid update is applied anyway.
+	w = doRequest(router, "POST", "/flat/update/bulk", token, models.FlatUpdateBulkPostRequest{
+		Items: []models.FlatUpdateBulkItem{
+			{Id: first.Id, Status: models.ON_MODERATION},
+			{Id: second.Id, Status: models.APPROVED},
+		},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.BULK_BEST_EFFORT, response.Mode)
+	assert.True(t, response.Results[0].Success)
+	assert.Equal(t, models.ON_MODERATION, response.Results[0].Flat.Status)
+	assert.False(t, response.Results[1].Success)
+
+	reason := "Duplicate listing"
+	w = doRequest(router, "POST", "/flat/update/bulk", token, models.FlatUpdateBulkPostRequest{
+		Items:  []models.FlatUpdateBulkItem{{Id: first.Id, Status: models.DECLINED}},
+		Reason: &reason,
+		Mode:   models.BULK_ATOMIC,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Results[0].Success)
+	assert.Equal(t, models.DECLINED, response.Results[0].Flat.Status)
+}
+
+// stoppingFlats applies the first update of a batch and times out on the
+// second, as a best-effort batch against a slow database would.
+type stoppingFlats struct {
+	database.FlatRepository
+}
+
+func (f stoppingFlats) UpdateFlatStatuses(ctx context.Context, updates []database.FlatStatusUpdate, moderatorID int, reason *string, lease time.Duration, atomic bool) ([]database.FlatStatusUpdateResult, error) {
+	results := []database.FlatStatusUpdateResult{
+		{Flat: &models.Flat{Id: updates[0].Id, Status: updates[0].Status}},
+		{Err: context.DeadlineExceeded},
+	}
+	for range updates[2:] {
+		results = append(results, database.FlatStatusUpdateResult{Err: database.ErrNotAttempted})
+	}
+	return results, nil
+}
+
+func TestFlatUpdateBulkPostPartialFailure(t *testing.T) {
+	router := routers.NewRouter(routers.ApiHandleFunctions{
+		ModerationsOnlyAPI: api.ModerationsOnlyAPI{Houses: store, Flats: stoppingFlats{}},
+		NoAuthAPI:          api.NoAuthAPI{Users: store, Subscriptions: store, Tokens: store},
+		Revocations:        store,
+	})
+
+	token, err := getToken(router, "moderator")
+	require.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/update/bulk", token, models.FlatUpdateBulkPostRequest{
+		Items: []models.FlatUpdateBulkItem{
+			{Id: 1, Status: models.ON_MODERATION},
+			{Id: 2, Status: models.ON_MODERATION},
+			{Id: 3, Status: models.ON_MODERATION},
+		},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.FlatUpdateBulkPost200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 3, len(response.Results))
+	assert.True(t, response.Results[0].Success)
+	assert.Equal(t, models.ERROR_TIMEOUT, response.Results[1].Error.Code)
+	assert.Equal(t, models.ERROR_NOT_ATTEMPTED, response.Results[2].Error.Code)
+}
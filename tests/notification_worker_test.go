@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/notifications"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outcome is what the worker recorded for a notification.
+type outcome struct {
+	sent          bool
+	dead          bool
+	nextAttemptAt time.Time
+	// ctxErr is the error of the context the outcome was recorded with.
+	ctxErr error
+}
+
+// stubOutbox hands out its notifications once and records their outcomes.
+type stubOutbox struct {
+	pending  []models.Notification
+	outcomes map[int32]outcome
+}
+
+func newStubOutbox(pending ...models.Notification) *stubOutbox {
+	return &stubOutbox{pending: pending, outcomes: map[int32]outcome{}}
+}
+
+func (o *stubOutbox) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]models.Notification, error) {
+	claimed := o.pending
+	o.pending = nil
+	return claimed, nil
+}
+
+func (o *stubOutbox) MarkNotificationSent(ctx context.Context, id int32) error {
+	o.outcomes[id] = outcome{sent: true, ctxErr: ctx.Err()}
+	return nil
+}
+
+func (o *stubOutbox) MarkNotificationFailed(ctx context.Context, id int32, lastError string, nextAttemptAt time.Time, dead bool) error {
+	o.outcomes[id] = outcome{dead: dead, nextAttemptAt: nextAttemptAt, ctxErr: ctx.Err()}
+	return nil
+}
+
+// countingSender counts messages and fails every one of them with err.
+type countingSender struct {
+	sent int
+	err  error
+	// onSend runs before each message is counted.
+	onSend func()
+}
+
+func (s *countingSender) Send(ctx context.Context, message notifications.Message) error {
+	if s.onSend != nil {
+		s.onSend()
+	}
+	s.sent++
+	return s.err
+}
+
+// approvedNotification renders fine.
+func approvedNotification(t *testing.T, id, attempts int32) models.Notification {
+	payload, err := json.Marshal(models.FlatApprovedPayload{SubscriptionId: 1, HouseId: 1, FlatNumber: 1, Price: 100, Rooms: 1})
+	require.NoError(t, err)
+	return models.Notification{Id: id, Kind: models.FLAT_APPROVED, Recipient: "someone@example.com", Payload: payload, Attempts: attempts}
+}
+
+func TestWorkerDeadLettersUnrenderableNotifications(t *testing.T) {
+	outbox := newStubOutbox(models.Notification{Id: 1, Kind: "unknown", Recipient: "someone@example.com"})
+	sender := &countingSender{}
+
+	worker := notifications.NewWorker(outbox, sender)
+	processed, err := worker.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+
+	assert.Equal(t, 0, sender.sent)
+	assert.True(t, outbox.outcomes[1].dead)
+}
+
+func TestWorkerBacksOffAndDeadLetters(t *testing.T) {
+	for _, tc := range []struct {
+		// attempts made before this one.
+		attempts int32
+		delay    time.Duration
+		dead     bool
+	}{
+		{attempts: 0, delay: 30 * time.Second},
+		{attempts: 1, delay: time.Minute},
+		{attempts: 2, delay: 2 * time.Minute},
+		{attempts: 4, delay: 8 * time.Minute},
+		{attempts: 6, delay: 32 * time.Minute},
+		// The delay is capped at MaxBackoff, and the eighth failure is the
+		// last one.
+		{attempts: 7, delay: time.Hour, dead: true},
+		{attempts: 20, delay: time.Hour, dead: true},
+	} {
+		outbox := newStubOutbox(approvedNotification(t, 1, tc.attempts))
+		worker := notifications.NewWorker(outbox, &countingSender{err: errors.New("mailbox unavailable")})
+		require.Equal(t, int32(8), worker.MaxAttempts)
+		require.Equal(t, time.Hour, worker.MaxBackoff)
+
+		start := time.Now()
+		_, err := worker.RunOnce(context.Background())
+		assert.NoError(t, err)
+
+		result := outbox.outcomes[1]
+		assert.False(t, result.sent)
+		assert.Equal(t, tc.dead, result.dead, "after %d attempts", tc.attempts)
+		assert.WithinDuration(t, start.Add(tc.delay), result.nextAttemptAt, time.Second, "after %d attempts", tc.attempts)
+	}
+}
+
+func TestWorkerMarksSentMessagesAfterShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	outbox := newStubOutbox(approvedNotification(t, 1, 0), approvedNotification(t, 2, 0))
+	// The worker is stopped while the first message is being sent.
+	sender := &countingSender{onSend: cancel}
+
+	worker := notifications.NewWorker(outbox, sender)
+	_, err := worker.RunOnce(ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, sender.sent)
+	assert.Equal(t, outcome{sent: true}, outbox.outcomes[1])
+	// The second one is left claimed rather than counted as a failure.
+	assert.NotContains(t, outbox.outcomes, int32(2))
+}
+
+func TestSMTPSenderTimesOut(t *testing.T) {
+	// The server accepts connections but never greets the client.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	sender := &notifications.SMTPSender{Host: "127.0.0.1", Port: addr.Port, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	err = sender.Send(context.Background(), notifications.Message{To: "someone@example.com"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender.Timeout = time.Minute
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	err = sender.Send(ctx, notifications.Message{To: "someone@example.com"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
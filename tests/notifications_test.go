@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"avito-backend-bootcamp/notifications"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func doRequest(router *gin.Engine, method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	if payload != nil {
+		payloadBytes, _ := json.Marshal(payload)
+		body.Write(payloadBytes)
+	}
+
+	req, _ := http.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestFlatApprovedNotification(t *testing.T) {
+	router := newTestRouter()
+
+	moderatorToken, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/house/create", moderatorToken, models.HouseCreatePostRequest{
+		Address: "TestAddressNotification",
+		Year:    2024,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var house models.House
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &house))
+
+	w = doRequest(router, "POST", "/house/"+itoa(house.Id)+"/subscribe", moderatorToken, models.HouseIdSubscribePostRequest{
+		Email: "dummylogin+moderator@example.com",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/flat/create", moderatorToken, models.FlatCreatePostRequest{
+		HouseId:    house.Id,
+		FlatNumber: 1,
+		Price:      1000,
+		Rooms:      1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var flat models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &flat))
+
+	w = doRequest(router, "POST", "/flat/update", moderatorToken, models.FlatUpdatePostRequest{
+		Id:     flat.Id,
+		Status: models.ON_MODERATION,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "POST", "/flat/update", moderatorToken, models.FlatUpdatePostRequest{
+		Id:     flat.Id,
+		Status: models.APPROVED,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var output bytes.Buffer
+	worker := notifications.NewWorker(store, &notifications.LogSender{Writer: &output})
+	for {
+		processed, err := worker.RunOnce(context.Background())
+		assert.NoError(t, err)
+		if err != nil || processed == 0 {
+			break
+		}
+	}
+
+	assert.True(t, strings.Contains(output.String(), "To: dummylogin+moderator@example.com"))
+}
+
+func itoa(id int32) string {
+	return strconv.Itoa(int(id))
+}
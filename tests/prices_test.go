@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatPriceHistory(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+	author := registerAndLogin(t, router, "price-author@example.com", models.CLIENT)
+	house := createHouse(t, router, moderator, "Price drop avenue 3")
+
+	flat := createFlat(t, router, author, house.Id, 1)
+	pending := createFlat(t, router, author, house.Id, 2)
+
+	w := doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.ON_MODERATION})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: flat.Id, Status: models.APPROVED})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	price := flat.Price * 3 / 4
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), moderator, models.FlatIdPatchRequest{Price: &price})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/house/"+itoa(house.Id), client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page models.HouseIdGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, len(page.Flats))
+	assert.True(t, page.Flats[0].PriceDropped)
+	assert.Equal(t, 25.0, *page.Flats[0].PriceDropPercent)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(flat.Id)+"/prices", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var prices models.FlatIdPricesGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &prices))
+	assert.Equal(t, 2, len(prices.Prices))
+	assert.Equal(t, flat.Price, prices.Prices[0].Price)
+	assert.Equal(t, price, prices.Prices[1].Price)
+
+	// Flats waiting for moderation are only visible to their author.
+	w = doRequest(router, "GET", "/flat/"+itoa(pending.Id)+"/prices", client, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(router, "GET", "/flat/"+itoa(pending.Id)+"/prices", author, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &prices))
+	assert.Equal(t, 1, len(prices.Prices))
+	assert.False(t, prices.Prices[0].ChangedAt.IsZero())
+}
+
+func TestFlatPriceValidation(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Kopeck lane 5")
+
+	for _, price := range []int64{0, -100} {
+		w := doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 1, Price: price, Rooms: 1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	w := doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 1, Price: 100, Currency: "XYZ", Rooms: 1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Prices beyond int32 fit, and the currency defaults to roubles.
+	w = doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{HouseId: house.Id, FlatNumber: 1, Price: 350000000000, Rooms: 5})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var flat models.Flat
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &flat))
+	assert.Equal(t, int64(350000000000), flat.Price)
+	assert.Equal(t, models.DefaultCurrency, flat.Currency)
+
+	currency := "usd"
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), moderator, models.FlatIdPatchRequest{Currency: &currency})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &flat))
+	assert.Equal(t, "USD", flat.Currency)
+
+	price := int64(-1)
+	w = doRequest(router, "PATCH", "/flat/"+itoa(flat.Id), moderator, models.FlatIdPatchRequest{Price: &price})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
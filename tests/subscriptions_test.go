@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/auth"
+	"avito-backend-bootcamp/models"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHouseIdSubscribePostClient(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	token := registerAndLogin(t, router, "subscriber@example.com", models.CLIENT)
+	path := "/house/" + itoa(createHouse(t, router, moderator, "Subscribe street 1").Id) + "/subscribe"
+
+	payload := models.HouseIdSubscribePostRequest{
+		Email: "Subscriber@example.com",
+	}
+
+	for i := 0; i < 2; i++ {
+		payloadBytes, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(payloadBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Without a body the caller's own email is subscribed.
+	w := doRequest(router, "POST", path, token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	var subscriptions models.SubscriptionsGet200Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &subscriptions))
+	assert.Equal(t, 1, len(subscriptions.Subscriptions))
+
+	w = doRequest(router, "POST", path, token, models.HouseIdSubscribePostRequest{
+		Email: "stranger@example.com",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ERROR_FORBIDDEN, response.Code)
+}
+
+func TestHouseIdSubscribePostUnknownHouse(t *testing.T) {
+	router := newTestRouter()
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	payload := models.HouseIdSubscribePostRequest{
+		Email: "dummylogin+client@example.com",
+	}
+
+	payloadBytes, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/house/999999/subscribe", bytes.NewBuffer(payloadBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHouseIdSubscribePostInvalidEmail(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Invalid email street 1")
+
+	token, err := getToken(router, "client")
+	assert.NoError(t, err)
+
+	payload := models.HouseIdSubscribePostRequest{
+		Email: "not-an-email",
+	}
+
+	payloadBytes, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/house/"+itoa(house.Id)+"/subscribe", bytes.NewBuffer(payloadBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSubscriptionsGetAndDelete(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	token := registerAndLogin(t, router, "listed-subscriber@example.com", models.CLIENT)
+	house := createHouse(t, router, moderator, "Listed street 1")
+
+	w := doRequest(router, "POST", "/house/"+itoa(house.Id)+"/subscribe", token, models.HouseIdSubscribePostRequest{
+		Email: "listed-subscriber@example.com",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.SubscriptionsGet200Response
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(response.Subscriptions))
+	assert.Equal(t, house.Id, response.Subscriptions[0].HouseId)
+
+	w = doRequest(router, "DELETE", "/subscriptions/"+itoa(response.Subscriptions[0].Id), token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(response.Subscriptions))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	token := registerAndLogin(t, router, "unsubscribe@example.com", models.CLIENT)
+	house := createHouse(t, router, moderator, "Unsubscribe street 1")
+
+	w := doRequest(router, "POST", "/house/"+itoa(house.Id)+"/subscribe", token, models.HouseIdSubscribePostRequest{
+		Email: "unsubscribe@example.com",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	var response models.SubscriptionsGet200Response
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Subscriptions))
+
+	unsubscribeToken, err := auth.GenerateUnsubscribeToken(response.Subscriptions[0].Id, "unsubscribe@example.com")
+	assert.NoError(t, err)
+
+	claims, err := auth.ValidateUnsubscribeToken(unsubscribeToken)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.Id)
+	assert.Greater(t, claims.ExpiresAt, time.Now().Unix())
+
+	// Following the link only shows a confirmation form.
+	w = doRequest(router, "GET", "/unsubscribe?token="+unsubscribeToken, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<form method="post" action="/unsubscribe">`)
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Subscriptions))
+
+	form := url.Values{"token": {unsubscribeToken}}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "/unsubscribe", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w = doRequest(router, "GET", "/subscriptions", token, nil)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(response.Subscriptions))
+
+	w = doRequest(router, "GET", "/unsubscribe?token=invalid", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(router, "POST", "/unsubscribe?token=invalid", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
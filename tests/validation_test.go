@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"avito-backend-bootcamp/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fieldCodes decodes a validation error response into a map of field codes.
+func fieldCodes(t *testing.T, body []byte) map[string]string {
+	var response models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, models.ERROR_VALIDATION_FAILED, response.Code)
+
+	codes := map[string]string{}
+	for _, field := range response.Fields {
+		assert.NotEmpty(t, field.Message)
+		codes[field.Field] = field.Code
+	}
+	return codes
+}
+
+func TestRegisterPostValidation(t *testing.T) {
+	router := newTestRouter()
+
+	w := doRequest(router, "POST", "/register", "", models.RegisterPostRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"email":     models.FIELD_REQUIRED,
+		"password":  models.FIELD_REQUIRED,
+		"user_type": models.FIELD_REQUIRED,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/register", "", models.RegisterPostRequest{
+		Email:    "not-an-email",
+		Password: "password",
+		UserType: "admin",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"email":     models.FIELD_INVALID_EMAIL,
+		"password":  models.FIELD_WEAK_PASSWORD,
+		"user_type": models.FIELD_INVALID_VALUE,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/login", "", models.LoginPostRequest{Email: "validation@example.com"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"password": models.FIELD_REQUIRED}, fieldCodes(t, w.Body.Bytes()))
+}
+
+func TestFlatAndHouseCreateValidation(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/create", moderator, models.FlatCreatePostRequest{
+		FlatNumber: -1,
+		Price:      -100,
+		Currency:   "XYZ",
+		Rooms:      2,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"house_id":    models.FIELD_REQUIRED,
+		"flat_number": models.FIELD_TOO_SMALL,
+		"price":       models.FIELD_TOO_SMALL,
+		"currency":    models.FIELD_INVALID_CURRENCY,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/flat/create", moderator, map[string]interface{}{"house_id": "one"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"house_id": models.FIELD_INVALID_TYPE}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/house/create", moderator, models.HouseCreatePostRequest{
+		Year: int32(time.Now().Year() + 50),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"address": models.FIELD_REQUIRED,
+		"year":    models.FIELD_TOO_LARGE,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	// Houses under construction may be completed in the next few years.
+	w = doRequest(router, "POST", "/house/create", moderator, models.HouseCreatePostRequest{
+		Address: "Future street 1",
+		Year:    int32(time.Now().Year() + 2),
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestFlatUpdateValidation(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+
+	w := doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Status: "sold"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"id":     models.FIELD_REQUIRED,
+		"status": models.FIELD_INVALID_VALUE,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/flat/update", moderator, models.FlatUpdatePostRequest{Id: -1, Status: models.APPROVED})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"id": models.FIELD_TOO_SMALL}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/flat/update/bulk", moderator, models.FlatUpdateBulkPostRequest{
+		Items: []models.FlatUpdateBulkItem{{Id: 1, Status: models.APPROVED}, {Status: "sold"}},
+		Mode:  "eventually",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{
+		"items[1].id":     models.FIELD_REQUIRED,
+		"items[1].status": models.FIELD_INVALID_VALUE,
+		"mode":            models.FIELD_INVALID_VALUE,
+	}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "POST", "/flat/update/bulk", moderator, models.FlatUpdateBulkPostRequest{Items: []models.FlatUpdateBulkItem{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"items": models.FIELD_TOO_SMALL}, fieldCodes(t, w.Body.Bytes()))
+}
+
+func TestQueryAndPathValidation(t *testing.T) {
+	router := newTestRouter()
+
+	moderator, err := getToken(router, "moderator")
+	assert.NoError(t, err)
+	client, err := getToken(router, "client")
+	assert.NoError(t, err)
+	house := createHouse(t, router, moderator, "Query street 1")
+
+	w := doRequest(router, "GET", "/house/"+itoa(house.Id)+"?rooms=two", client, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"rooms": models.FIELD_INVALID_TYPE}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "GET", "/houses?limit=1000", client, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"limit": models.FIELD_TOO_LARGE}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "GET", "/houses?cursor=garbage", client, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"cursor": models.FIELD_INVALID_VALUE}, fieldCodes(t, w.Body.Bytes()))
+
+	w = doRequest(router, "GET", "/house/abc", client, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"id": models.FIELD_INVALID_TYPE}, fieldCodes(t, w.Body.Bytes()))
+
+	blank := "   "
+	w = doRequest(router, "PATCH", "/house/"+itoa(house.Id), moderator, models.HouseIdPatchRequest{Address: &blank})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"address": models.FIELD_REQUIRED}, fieldCodes(t, w.Body.Bytes()))
+
+	var response models.ErrorResponse
+	w = doRequest(router, "GET", "/house/"+itoa(house.Id)+"?status=created", client, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ERROR_FORBIDDEN, response.Code)
+
+	w = doRequest(router, "GET", "/house/999999", client, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ERROR_HOUSE_NOT_FOUND, response.Code)
+}